@@ -0,0 +1,128 @@
+package fptest
+
+import (
+	"container/heap"
+	"math/big"
+)
+
+// A HardCase is a hard case found by an enumeration.
+type HardCase struct {
+	// X is the float (for HalfDecimals and NearFloats) or the float
+	// below the midpoint (for Midpoints).
+	X   float64
+	Exp int // binary exponent of X
+	// N and K describe the decimal number: N×10^K for Midpoints
+	// and NearFloats, (N+1/2)×10^K for HalfDecimals.
+	N uint64
+	K int
+	// Eps is the relative difference between the decimal number
+	// and the binary number (the midpoint or X).
+	Eps float64
+}
+
+// BestFirst enumerates hard cases for all exponents of format f,
+// from the hardest to the easiest (by increasing |Eps|), with
+// a relative difference less than 2^-precision. The decimal exponent
+// is chosen as in AlmostDecimalMidpoint for the given number of digits.
+// Exact cases (Eps = 0) are not included: for small exponents they are
+// too numerous and are better found by direction 0 of the enumerators.
+//
+// The enumeration stops after count cases if count is positive.
+func BestFirst(f Format, m Mode, digits int, precision uint, count int, fn func(c HardCase)) {
+	var q streamHeap
+	for e2 := f.MinExp; e2 <= f.MaxExp; e2++ {
+		q.add(f.target(m, e2, digits, false), e2, precision)
+	}
+	q.add(f.target(m, f.MinExp, digits, true), f.MinExp, precision)
+	emitted := 0
+	q.walk(func(c HardCase, denormal bool) bool {
+		fn(c)
+		emitted++
+		return count <= 0 || emitted < count
+	})
+}
+
+// walk enumerates the cases of the streams of h by increasing |Eps|,
+// until fn returns false.
+func (h *streamHeap) walk(fn func(c HardCase, denormal bool) bool) {
+	heap.Init(h)
+	for h.Len() > 0 {
+		s := (*h)[0]
+		stop := false
+		s.t.emitAll(s.it.r, func(x float64, n uint64, k int) {
+			if stop {
+				return
+			}
+			stop = !fn(HardCase{X: x, Exp: s.exp, N: n, K: k, Eps: s.eps}, s.t.denormal)
+		})
+		if stop {
+			return
+		}
+		s.it.next()
+		if s.it.done || !s.update() {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+}
+
+// A stream walks the Farey range on one side of a target.
+type stream struct {
+	t   *target
+	x   *big.Rat // the target X
+	max *big.Rat // 2^-precision
+	exp int
+	it  *ratIter
+	eps float64 // signed relative difference between it.r and X
+	abs float64
+}
+
+// update computes the relative difference of the current fraction
+// and returns whether it is less than 2^-precision. Below X, the range
+// ends with a fraction which may be farther.
+func (s *stream) update() bool {
+	e := s.it.r.BigRat()
+	e.Quo(e, s.x)
+	e.Sub(e, big.NewRat(1, 1))
+	s.eps, _ = e.Float64()
+	s.abs = s.eps
+	if s.abs < 0 {
+		s.abs = -s.abs
+	}
+	return e.Abs(e).Cmp(s.max) < 0
+}
+
+// A streamHeap is a priority queue of streams ordered by |eps|.
+type streamHeap []*stream
+
+// add appends the streams below and above the target t.
+func (h *streamHeap) add(t *target, e2 int, precision uint) {
+	if t == nil {
+		return
+	}
+	x := new(big.Rat).SetFrac(t.num, t.den)
+	max := new(big.Rat).SetFrac(big.NewInt(1), pow2Big(precision))
+	for _, dir := range []int{-1, +1} {
+		it := newRatIter(t.num, t.den, precision, dir, t.nbits)
+		if it.done {
+			continue
+		}
+		s := &stream{t: t, x: x, max: max, exp: e2, it: it}
+		if s.update() {
+			*h = append(*h, s)
+		}
+	}
+}
+
+func (h streamHeap) Len() int            { return len(h) }
+func (h streamHeap) Less(i, j int) bool  { return h[i].abs < h[j].abs }
+func (h streamHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *streamHeap) Push(x interface{}) { *h = append(*h, x.(*stream)) }
+
+func (h *streamHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,110 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// caseEps computes the relative difference between the decimal
+// and binary numbers of a hard case.
+func caseEps(f Format, m Mode, x float64, n uint64, k int) float64 {
+	dec := new(big.Rat).SetInt(new(big.Int).SetUint64(n))
+	bin := new(big.Rat)
+	bin.SetFloat64(x)
+	if m == Midpoints {
+		// Add half an ulp (which may not be a float64).
+		_, e := math.Frexp(x)
+		e -= int(f.MantBits)
+		if x == 0 || e < f.MinExp {
+			e = f.MinExp
+		}
+		bin.Add(bin, pow2Rat(e-1))
+	} else if m == HalfDecimals {
+		dec.Add(dec, big.NewRat(1, 2))
+	}
+	p := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(k))), nil))
+	if k >= 0 {
+		dec.Mul(dec, p)
+	} else {
+		dec.Quo(dec, p)
+	}
+	eps, _ := dec.Quo(dec, bin).Sub(dec, big.NewRat(1, 1)).Float64()
+	return eps
+}
+
+func pow2Rat(e int) *big.Rat {
+	p := new(big.Rat).SetInt(pow2Big(uint(abs(e))))
+	if e < 0 {
+		p.Inv(p)
+	}
+	return p
+}
+
+func TestBestFirst(t *testing.T) {
+	const digits = 8
+	const prec = 48
+	for _, m := range []Mode{Midpoints, HalfDecimals, NearFloats} {
+		// All cases, sorted.
+		var all []float64
+		collect := func(x float64, n uint64, k int) {
+			all = append(all, math.Abs(caseEps(Float32, m, x, n, k)))
+		}
+		enum := AlmostDecimalMidpoint
+		switch m {
+		case HalfDecimals:
+			enum = AlmostHalfDecimal
+		case NearFloats:
+			enum = AlmostDecimal
+		}
+		for e2 := Float32.MinExp; e2 <= Float32.MaxExp; e2++ {
+			for _, dir := range []int{-1, 1} {
+				enum(e2, digits, 24, prec, dir, false, collect)
+				if e2 == Float32.MinExp {
+					enum(e2, digits, 23, prec, dir, true, collect)
+				}
+			}
+		}
+		sort.Float64s(all)
+
+		const count = 200
+		var got []HardCase
+		BestFirst(Float32, m, digits, prec, count, func(c HardCase) {
+			got = append(got, c)
+		})
+		if len(got) != count || len(all) < count {
+			t.Fatalf("%s: got %d cases, expected %d (out of %d)", m, len(got), count, len(all))
+		}
+		for i, c := range got {
+			eps := caseEps(Float32, m, c.X, c.N, c.K)
+			if math.Abs(eps-c.Eps) > 1e-6*math.Abs(eps) {
+				t.Errorf("%s: %+v: eps=%g", m, c, eps)
+			}
+			if math.Abs(math.Abs(eps)-all[i]) > 1e-6*all[i] {
+				t.Errorf("%s: case %d is %+v with |eps|=%g, expected %g", m, i, c, math.Abs(eps), all[i])
+			}
+		}
+		t.Logf("%s: %d cases, hardest %+v, %dth %+v", m, len(all), got[0], count, got[count-1])
+	}
+}
+
+func TestBestFirstPrecision(t *testing.T) {
+	// Below X, the Farey ranges end with a fraction which
+	// may be beyond the precision: it must not be listed.
+	const prec = 50
+	max := math.Ldexp(1, -prec)
+	for _, m := range []Mode{Midpoints, HalfDecimals, NearFloats} {
+		n := 0
+		BestFirst(Float32, m, 9, prec, 0, func(c HardCase) {
+			n++
+			if math.Abs(c.Eps) >= max {
+				t.Errorf("%s: %+v is beyond 2^-%d", m, c, prec)
+			}
+		})
+		if n == 0 {
+			t.Errorf("%s: no cases", m)
+		}
+		t.Logf("%s: %d cases", m, n)
+	}
+}
@@ -0,0 +1,186 @@
+package fptest
+
+import (
+	"math/big"
+)
+
+// AlmostDecimalMidpointBig is like AlmostDecimalMidpoint for an
+// arbitrary number of mantissa bits, such as the precisions of
+// big.Float (64, 113 or 256 bits). The float is returned as an exact
+// big.Float with precision mantbits, and only cases with a relative
+// difference less than 2^-precision are returned.
+//
+// Since denominators do not fit in 64 bits, the Farey sequence is walked
+// using big integers. For 10^digits decimals, there are about
+// 2^(mantbits-precision)×10^digits cases per exponent, so precision
+// should be about mantbits+3.4×digits.
+func AlmostDecimalMidpointBig(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	f func(x *big.Float, n uint64, k int)) {
+	if t := midpointTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walkBig(precision, -direction, t.bigFloats(mantbits, f))
+	}
+}
+
+// AlmostHalfDecimalBig is like AlmostHalfDecimal for an arbitrary
+// number of mantissa bits (see AlmostDecimalMidpointBig).
+func AlmostHalfDecimalBig(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	f func(x *big.Float, n uint64, k int)) {
+	if t := halfDecimalTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walkBig(precision, -direction, t.bigFloats(mantbits, f))
+	}
+}
+
+// bigFloats returns a callback for walkBig calling f
+// with cases as big.Float values.
+func (t *target) bigFloats(mantbits uint, f func(x *big.Float, n uint64, k int)) func(n, mant *big.Int) {
+	return func(n, mant *big.Int) {
+		if !n.IsUint64() {
+			return
+		}
+		x := new(big.Float).SetPrec(mantbits).SetInt(mant)
+		x.SetMantExp(x, t.exp2)
+		f(x, n.Uint64(), t.exp10)
+	}
+}
+
+// A bigFrac is a fraction p/q of big integers.
+type bigFrac struct{ p, q *big.Int }
+
+func (r bigFrac) cmp(s bigFrac) int {
+	return new(big.Int).Mul(r.p, s.q).Cmp(new(big.Int).Mul(s.p, r.q))
+}
+
+// walkBig is like walk, using big integers for fractions. It calls f
+// with the decimal mantissa n and the binary mantissa of each case
+// (see emitBig).
+func (t *target) walkBig(precision uint, direction int, f func(n, mant *big.Int)) {
+	N := new(big.Int).Lsh(big.NewInt(1), t.nbits)
+	N.Sub(N, big.NewInt(1))
+	X := bigFrac{t.num, t.den}
+	lo, hi := fareyBounds(t.num, t.den, N)
+	if direction == 0 {
+		if lo.cmp(hi) == 0 {
+			t.emitBig(lo, f)
+		}
+		return
+	}
+	if lo.cmp(hi) == 0 {
+		// X is in the sequence: find its neighbour, the bound
+		// of X - 1/(2N^2).
+		n2 := new(big.Int).Mul(N, N)
+		n2.Lsh(n2, 1)
+		lo, _ = fareyBounds(
+			new(big.Int).Sub(new(big.Int).Mul(t.num, n2), t.den),
+			new(big.Int).Mul(t.den, n2), N)
+		if direction > 0 {
+			lo, hi = hi, fareyNext(lo, hi, N)
+		}
+	}
+	// Walk away from X, starting from r with neighbour prev.
+	prev, r := hi, lo
+	if direction > 0 {
+		prev, r = lo, hi
+	}
+	for r.p.Sign() > 0 && withinBig(r, X, precision) {
+		t.emitBig(r, f)
+		prev, r = r, fareyNext(prev, r, N)
+	}
+}
+
+// withinBig returns whether r is within a relative difference
+// 2^-precision of X.
+func withinBig(r, X bigFrac, precision uint) bool {
+	y := new(big.Int).Mul(r.q, X.p)
+	d := new(big.Int).Mul(r.p, X.q)
+	d.Sub(d, y)
+	d.Abs(d)
+	return d.Lsh(d, precision).Cmp(y) < 0
+}
+
+// fareyNext returns the term following r in the Farey sequence
+// of order N, in the direction from prev to r (prev and r must be
+// consecutive terms).
+func fareyNext(prev, r bigFrac, N *big.Int) bigFrac {
+	// k = floor((N + prev.q) / r.q)
+	k := new(big.Int).Add(N, prev.q)
+	k.Quo(k, r.q)
+	p := new(big.Int).Mul(k, r.p)
+	q := new(big.Int).Mul(k, r.q)
+	return bigFrac{p.Sub(p, prev.p), q.Sub(q, prev.q)}
+}
+
+// fareyBounds returns the consecutive terms lo <= X <= hi of the Farey
+// sequence of order N around X = num/den, with lo = hi = X if X is
+// in the sequence. They are the last convergent of X, and the last
+// semiconvergent, with denominators at most N.
+func fareyBounds(num, den, N *big.Int) (lo, hi bigFrac) {
+	num, den = new(big.Int).Set(num), new(big.Int).Set(den)
+	h2, k2 := big.NewInt(0), big.NewInt(1)
+	h1, k1 := big.NewInt(1), big.NewInt(0)
+	a := new(big.Int)
+	for {
+		a.QuoRem(num, den, num)
+		num, den = den, num
+		h := new(big.Int).Add(new(big.Int).Mul(a, h1), h2)
+		k := new(big.Int).Add(new(big.Int).Mul(a, k1), k2)
+		if k.Cmp(N) > 0 {
+			// The largest semiconvergent (t h1 + h2)/(t k1 + k2).
+			t := new(big.Int).Sub(N, k2)
+			t.Quo(t, k1)
+			s := bigFrac{
+				new(big.Int).Add(new(big.Int).Mul(t, h1), h2),
+				new(big.Int).Add(new(big.Int).Mul(t, k1), k2),
+			}
+			c := bigFrac{h1, k1}
+			if s.cmp(c) < 0 {
+				return s, c
+			}
+			return c, s
+		}
+		if den.Sign() == 0 {
+			return bigFrac{h, k}, bigFrac{h, k}
+		}
+		h2, k2, h1, k1 = h1, k1, h, k
+	}
+}
+
+// emitBig calls f for the cases given by r and its multiples,
+// with the decimal mantissa n (such that the decimal is n×10^exp10
+// or (n+1/2)×10^exp10) and the binary mantissa of the float x = mant×2^exp2.
+func (t *target) emitBig(r bigFrac, f func(n, mant *big.Int)) {
+	odd := r.q
+	if t.parity == OddNumerator {
+		odd = r.p
+	}
+	if odd.Bit(0) == 0 {
+		return
+	}
+	// Multiples j×r such that j×q has nbits bits (at most if denormal).
+	one := big.NewInt(1)
+	max := new(big.Int).Lsh(one, t.nbits)
+	jmin := big.NewInt(1)
+	if !t.denormal {
+		jmin.Rsh(max, 1)
+		jmin.Add(jmin, r.q)
+		jmin.Sub(jmin, one)
+		jmin.Quo(jmin, r.q)
+	}
+	jmax := new(big.Int).Sub(max, one)
+	jmax.Quo(jmax, r.q)
+	// The parity constraint requires odd multiples.
+	if jmin.Bit(0) == 0 {
+		jmin.Add(jmin, one)
+	}
+	step := big.NewInt(2)
+	for j := jmin; j.Cmp(jmax) <= 0; j = new(big.Int).Add(j, step) {
+		a := new(big.Int).Mul(j, r.p)
+		b := new(big.Int).Mul(j, r.q)
+		if t.parity == OddNumerator {
+			// x = b×2^exp2, the decimal is (n+1/2)×10^k with 2n+1 = a.
+			a.Rsh(a, 1)
+		} else {
+			b.Rsh(b, 1)
+		}
+		f(a, b)
+	}
+}
@@ -0,0 +1,69 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFareyBounds(t *testing.T) {
+	N := big.NewInt(1000)
+	for _, c := range []struct{ num, den, lo, hi string }{
+		{"314159265", "100000000", "2818/897", "355/113"},
+		{"1", "3", "1/3", "1/3"},
+		{"1000", "1", "1000/1", "1000/1"},
+		{"1", "1001", "0/1", "1/1000"},
+	} {
+		num, _ := new(big.Int).SetString(c.num, 10)
+		den, _ := new(big.Int).SetString(c.den, 10)
+		lo, hi := fareyBounds(num, den, N)
+		l := new(big.Rat).SetFrac(lo.p, lo.q).String()
+		h := new(big.Rat).SetFrac(hi.p, hi.q).String()
+		if l != c.lo || h != c.hi {
+			t.Errorf("bounds(%s/%s) = %s, %s, want %s, %s", c.num, c.den, l, h, c.lo, c.hi)
+		}
+	}
+}
+
+func TestAlmostDecimalMidpointBig(t *testing.T) {
+	// Compare with the 64-bit enumerators.
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		enum, enumBig := AlmostDecimalMidpoint, AlmostDecimalMidpointBig
+		if m == HalfDecimals {
+			enum, enumBig = AlmostHalfDecimal, AlmostHalfDecimalBig
+		}
+		for _, f := range []Format{Float32, Float64} {
+			digits, prec := 7, uint(42)
+			if f == Float64 {
+				digits, prec = 15, 98
+			}
+			count := 0
+			for e2 := f.MinExp; e2 <= f.MaxExp; e2 += 13 {
+				for _, dir := range []int{-1, 1} {
+					want := make(map[float64]bool)
+					enum(e2, digits, f.MantBits, prec, dir, false, func(x float64, n uint64, k int) {
+						if math.Abs(caseEps(f, m, x, n, k)) < math.Ldexp(1, -int(prec)) {
+							want[x] = true
+						}
+					})
+					got := 0
+					enumBig(e2, digits, f.MantBits, prec, dir, false, func(x *big.Float, n uint64, k int) {
+						got++
+						xf, acc := x.Float64()
+						if acc != big.Exact || !want[xf] {
+							t.Errorf("%s %s e2=%d dir=%d: unexpected %v (%de%d)", m, f.Name, e2, dir, x, n, k)
+						}
+						if eps := caseEps(f, m, xf, n, k); math.Abs(eps) >= math.Ldexp(1, -int(prec)) {
+							t.Errorf("%s %s e2=%d dir=%d: %de%d too far (eps=%g)", m, f.Name, e2, dir, n, k, eps)
+						}
+					})
+					if got != len(want) {
+						t.Errorf("%s %s e2=%d dir=%d: got %d cases, want %d", m, f.Name, e2, dir, got, len(want))
+					}
+					count += got
+				}
+			}
+			t.Logf("%s %s: %d cases", m, f.Name, count)
+		}
+	}
+}
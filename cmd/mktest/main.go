@@ -1,23 +1,120 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/remyoudompheng/fptest"
 )
 
 const basePrec = 64
 
+var (
+	maxDigits = flag.Int("digits", 6, "maximal number of digits (grisu, counts, stats, worst, explain modes, wide mode from 20 digits), or exact number of digits (best, products, doublerounding, ecmascript, check, spellings, longdecimals, fuzzcorpus, vectors modes)")
+	width     = flag.Uint("width", 64, "width of power of ten tables (products mode)")
+	format    = flag.String("format", "float64", "floating-point format: float32 or float64, float16 or bfloat16 in narrowing mode")
+	kind      = flag.String("mode", "midpoints", "kind of hard cases: midpoints, halfdecimals or nearfloats (best, counts, stats, worst, fuzzcorpus, ecmascript, explain, vectors modes)")
+	rounding  = flag.String("rounding", "", "rounding mode, as named by math/big (ToZero, ToPositiveInf...), to print expected results (best mode)")
+	count     = flag.Int("count", 1000, "maximal number of cases (best, fuzzcorpus, ecmascript, longdecimals, spellings, check, vectors modes)")
+	prec      = flag.Uint("prec", 0, "minimal relative precision in bits, 0 for default (best, counts, stats, fuzzcorpus, constant, ecmascript, longdecimals, spellings, check, explain, vectors modes)")
+	constant  = flag.String("const", "", "constant multiplier, as a fraction or decimal (constant mode)")
+	fuzzName  = flag.String("fuzz", "", "name of the fuzz test (fuzzcorpus mode)")
+	fuzzDir   = flag.String("dir", ".", "package directory of the fuzz test (fuzzcorpus mode)")
+	spelling  = flag.String("spell", "standard", "decimal spellings: standard or all, including Go syntax (spellings mode)")
+	seedType  = flag.String("seed", "decimal", "type of seeds: decimal, float or bits (fuzzcorpus mode)")
+	expStep   = flag.Int("step", 64, "number of binary exponents in each range (stats mode)")
+	lang      = flag.String("lang", "go", "language of test vectors: c, rust or go (vectors mode)")
+	pkg       = flag.String("pkg", "main", "package name of Go test vectors (vectors mode)")
+	minimize  = flag.Bool("minimize", false, "reduce the first mismatch of each mode to the simplest failing case, checking at most count cases per step (check mode)")
+)
+
 func main() {
-	hardMidpoints()
-	hardFloats()
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mktest [midpoints|floats|ryu32|ryu64|schubfach32|schubfach64|grisu|products|best|counts|fuzzcorpus|worst|constant|doublerounding|narrowing|ecmascript|longdecimals|wide|spellings|check|explain|stats|vectors]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch mode := flag.Arg(0); mode {
+	case "":
+		hardMidpoints()
+		hardFloats()
+	case "midpoints":
+		hardMidpoints()
+	case "floats":
+		hardFloats()
+	case "ryu32":
+		prove(fptest.RyuFloat32())
+		prove(fptest.RyuFloat32LastDigit())
+	case "ryu64":
+		prove(fptest.RyuFloat64())
+	case "schubfach32":
+		prove(fptest.SchubfachFloat32())
+	case "schubfach64":
+		prove(fptest.SchubfachFloat64())
+	case "grisu":
+		grisuFailures(*maxDigits)
+	case "products":
+		hardProducts(*maxDigits, *width)
+	case "best":
+		if *rounding != "" {
+			bestFirstRounding(parseFormat(*format), parseMode(*kind), parseRounding(*rounding), *maxDigits, *prec, *count)
+		} else {
+			bestFirst(parseFormat(*format), parseMode(*kind), *maxDigits, *prec, *count)
+		}
+	case "counts":
+		counts(parseFormat(*format), parseMode(*kind), *maxDigits, *prec)
+	case "stats":
+		stats(parseFormat(*format), parseMode(*kind), *maxDigits, *prec, *expStep)
+	case "worst":
+		worstCases(parseFormat(*format), parseMode(*kind), *maxDigits)
+	case "constant":
+		constantMultiples(parseFormat(*format), *constant, *prec)
+	case "doublerounding":
+		doubleRounding(*maxDigits)
+	case "narrowing":
+		narrowing(parseFormat(*format))
+	case "ecmascript":
+		ecmaScript(parseMode(*kind), *maxDigits, *prec, *count)
+	case "check":
+		check(parseFormat(*format), *maxDigits, *prec, *count, *minimize, flag.Args()[1:])
+	case "explain":
+		explain(parseFormat(*format), parseMode(*kind), *maxDigits, *prec, flag.Args()[1:])
+	case "spellings":
+		spellings(parseFormat(*format), *maxDigits, *prec, *count)
+	case "wide":
+		wideMidpoints(*maxDigits, *prec)
+	case "longdecimals":
+		longDecimals(parseFormat(*format), *maxDigits, *prec, *count)
+	case "vectors":
+		vectors(parseFormat(*format), parseMode(*kind), *maxDigits, *prec, *count)
+	case "fuzzcorpus":
+		fuzzCorpus(parseFormat(*format), parseMode(*kind), *maxDigits, *prec, *count)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// prove prints a certificate that truncated multiplications
+// in a Ryū-like algorithm are exact.
+func prove(m *fptest.MulShift) {
+	carries := m.Prove(os.Stdout)
+	if len(carries) > 0 {
+		log.Printf("%s: %d counterexamples", m.Name, len(carries))
+	}
 }
 
 func hardMidpoints() {
 	count := 0
-	show := func(x float64) {
+	show := func(x float64, n uint64, k int) {
 		mant, exp := math.Frexp(x)
 		f := new(big.Float).SetMantExp(
 			new(big.Float).SetInt64(int64(mant*(1<<54))+1),
@@ -46,6 +143,487 @@ func hardMidpoints() {
 	}
 }
 
+// grisuFailures lists float64 numbers which cannot
+// be formatted by Grisu3, or which are not formatted
+// in shortest form by Grisu2.
+func grisuFailures(maxDigits int) {
+	count := 0
+	show := func(x float64, fail fptest.GrisuFailure) {
+		count++
+		fmt.Printf("count=%08d %b %.17e %s\n", count, x, x, fail)
+	}
+	for digits := 1; digits <= maxDigits; digits++ {
+		fmt.Println("===", digits, "digits ===")
+		for exp := 0; exp < 1024-52; exp++ {
+			fptest.GrisuFailures(exp, digits, false, show)
+		}
+		for exp := 1; exp < 1024+52; exp++ {
+			if exp == 1023+52 {
+				// denormals
+				fptest.GrisuFailures(-(exp - 1), digits, true, show)
+			} else {
+				fptest.GrisuFailures(-exp, digits, false, show)
+			}
+		}
+	}
+}
+
+func parseFormat(s string) fptest.Format {
+	switch s {
+	case "float32":
+		return fptest.Float32
+	case "float64":
+		return fptest.Float64
+	case "float16":
+		return fptest.Float16
+	case "bfloat16":
+		return fptest.BFloat16
+	}
+	log.Fatalf("unknown format %q", s)
+	panic("unreachable")
+}
+
+func parseMode(s string) fptest.Mode {
+	switch s {
+	case "midpoints":
+		return fptest.Midpoints
+	case "halfdecimals":
+		return fptest.HalfDecimals
+	case "nearfloats":
+		return fptest.NearFloats
+	}
+	log.Fatalf("unknown mode %q", s)
+	panic("unreachable")
+}
+
+func parseRounding(s string) big.RoundingMode {
+	for rm := big.ToNearestEven; rm <= big.ToPositiveInf; rm++ {
+		if rm.String() == s {
+			return rm
+		}
+	}
+	log.Fatalf("unknown rounding mode %q", s)
+	panic("unreachable")
+}
+
+// defaultPrec returns the precision used by default
+// for a given format and number of digits.
+func defaultPrec(f fptest.Format, digits int) uint {
+	if f == fptest.Float32 {
+		return uint(2*digits) + 32
+	}
+	return uint(basePrec + 2*digits)
+}
+
+// counts prints the number of hard cases for each
+// exponent and number of digits, without enumerating them.
+func counts(f fptest.Format, m fptest.Mode, maxDigits int, prec uint) {
+	count := fptest.CountDecimalMidpoint
+	switch m {
+	case fptest.HalfDecimals:
+		count = fptest.CountHalfDecimal
+	case fptest.NearFloats:
+		count = fptest.CountDecimal
+	}
+	for digits := 1; digits <= maxDigits; digits++ {
+		p := prec
+		if p == 0 {
+			p = defaultPrec(f, digits)
+		}
+		fmt.Printf("=== %d digits, precision %d ===\n", digits, p)
+		var total [3]uint64
+		show := func(e2 int, mantbits uint, denormal bool) {
+			var n [3]uint64
+			for i, dir := range []int{-1, 0, +1} {
+				n[i] = count(e2, digits, mantbits, p, dir, denormal)
+				total[i] += n[i]
+			}
+			if n != [3]uint64{} {
+				fmt.Printf("e2=%d mantbits=%d below=%d exact=%d above=%d\n",
+					e2, mantbits, n[0], n[1], n[2])
+			}
+		}
+		show(f.MinExp, f.MantBits-1, true)
+		for e2 := f.MinExp; e2 <= f.MaxExp; e2++ {
+			show(e2, f.MantBits, false)
+		}
+		fmt.Printf("total below=%d exact=%d above=%d\n", total[0], total[1], total[2])
+	}
+}
+
+// bestFirst lists the hardest cases for all exponents,
+// from the hardest to the easiest.
+func bestFirst(f fptest.Format, m fptest.Mode, digits int, prec uint, count int) {
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	n := 0
+	fptest.BestFirst(f, m, digits, prec, count, func(c fptest.HardCase) {
+		n++
+		fmt.Printf("count=%08d %b %de%d eps=%+.3e (2^%.1f)\n",
+			n, c.X, c.N, c.K, c.Eps, math.Log2(math.Abs(c.Eps)))
+	})
+}
+
+// bestFirstRounding lists the hardest cases for a rounding mode,
+// with the expected results of parsing and formatting.
+func bestFirstRounding(f fptest.Format, m fptest.Mode, rm big.RoundingMode, digits int, prec uint, count int) {
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	n := 0
+	fptest.BestFirstRounding(f, m, rm, digits, prec, count, func(c fptest.Rounded) {
+		n++
+		fmt.Printf("count=%08d %b %de%d eps=%+.3e parse=%b format=%de%d\n",
+			n, c.X, c.N, c.K, c.Eps, c.Float, c.Decimal, c.K)
+	})
+}
+
+// stats prints histograms of the difficulty of cases for each number
+// of digits and range of binary exponents, with the hardest cases.
+// Ranges with more than twice the average number of cases per
+// exponent are marked as dense.
+func stats(f fptest.Format, m fptest.Mode, maxDigits int, prec uint, step int) {
+	start := time.Now()
+	for digits := 1; digits <= maxDigits; digits++ {
+		p := prec
+		if p == 0 {
+			p = defaultPrec(f, digits)
+		}
+		t := time.Now()
+		s := fptest.DifficultyStats(f, m, digits, p, step)
+		fmt.Printf("=== %s %s, %d digits, precision %d ===\n", f.Name, m, digits, p)
+		for i, n := range s.Counts {
+			fmt.Printf("2^-%d: %d\n", s.Min+uint(i), n)
+		}
+		fmt.Printf("total: %d cases, %d exact\n", s.Total(), s.Exact)
+		average := float64(s.Total()) / float64(s.MaxExp-s.MinExp+1)
+		for _, r := range s.Ranges {
+			dense := ""
+			if float64(r.Total()) > 2*average*float64(r.MaxExp-r.MinExp+1) {
+				dense = " dense"
+			}
+			fmt.Printf("e2=[%d,%d] %d cases %d exact%s", r.MinExp, r.MaxExp, r.Total(), r.Exact, dense)
+			if c := r.Hardest; c.ExactEps != nil {
+				fmt.Printf(" hardest %b %de%d (2^%.1f)", c.X, c.N, c.K, math.Log2(math.Abs(c.Eps)))
+			}
+			fmt.Println()
+			for i, n := range r.Counts {
+				fmt.Printf("  2^-%d: %d\n", r.Min+uint(i), n)
+			}
+		}
+		if c := s.Hardest; c.ExactEps != nil {
+			fmt.Printf("hardest: e2=%d %b %de%d eps=%+.3e (2^%.1f)\n",
+				c.Exp, c.X, c.N, c.K, c.Eps, math.Log2(math.Abs(c.Eps)))
+		}
+		fmt.Printf("time: %s\n", time.Since(t).Round(time.Millisecond))
+	}
+	fmt.Printf("total time: %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+// worstCases prints the hardest case for each exponent and number
+// of digits, and the precision needed to decide all of them.
+func worstCases(f fptest.Format, m fptest.Mode, maxDigits int) {
+	for digits := 1; digits <= maxDigits; digits++ {
+		fmt.Printf("=== %d digits ===\n", digits)
+		var hardest fptest.WorstCase
+		fptest.WorstCases(f, m, digits, func(c fptest.WorstCase) {
+			fmt.Printf("e2=%d denormal=%v %b %de%d eps=%+.3e (2^%.1f)\n",
+				c.Exp, c.Denormal, c.X, c.N, c.K, c.Eps, math.Log2(math.Abs(c.Eps)))
+			if hardest.ExactEps == nil || math.Abs(c.Eps) < math.Abs(hardest.Eps) {
+				hardest = c
+			}
+		})
+		if hardest.ExactEps == nil {
+			continue
+		}
+		fmt.Printf("hardest: %de%d eps=%s (precision %d bits)\n",
+			hardest.N, hardest.K, new(big.Float).SetPrec(64).SetRat(hardest.ExactEps).Text('e', 18),
+			int(math.Ceil(-math.Log2(math.Abs(hardest.Eps)))))
+	}
+}
+
+// constantMultiples lists floats in [1, 2) whose product
+// by a constant is very close to a midpoint.
+func constantMultiples(f fptest.Format, s string, prec uint) {
+	c, ok := new(big.Rat).SetString(s)
+	if !ok || c.Sign() <= 0 {
+		log.Fatalf("invalid constant %q", s)
+	}
+	if prec == 0 {
+		prec = 2 * f.MantBits
+	}
+	for _, dir := range []int{-1, 0, +1} {
+		fptest.AlmostMidpointMultiple(c, f, prec, dir, func(x, y float64) {
+			fmt.Printf("dir=%+d x=%b y=%b (%v × %s ≈ %v)\n", dir, x, y, x, c.RatString(), y)
+		})
+	}
+}
+
+// doubleRounding lists decimals which are incorrectly parsed
+// as float32 through a float64.
+func doubleRounding(digits int) {
+	show := func(x float64, n uint64, k int) {
+		x32 := float32(x)
+		fmt.Printf("%de%d float32=%b (double rounding gives %b)\n",
+			n, k, x32, float32(mustParse64(n, k)))
+	}
+	fptest.AlmostDoubleRounding(fptest.Float64, fptest.Float32, fptest.Float32.MinExp, digits, true, show)
+	for e2 := fptest.Float32.MinExp; e2 <= fptest.Float32.MaxExp; e2++ {
+		fptest.AlmostDoubleRounding(fptest.Float64, fptest.Float32, e2, digits, false, show)
+	}
+}
+
+func mustParse64(n uint64, k int) float64 {
+	x, err := strconv.ParseFloat(fmt.Sprintf("%de%d", n, k), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return x
+}
+
+// narrowing lists the float64 intervals which are converted
+// incorrectly to format f through a float32.
+func narrowing(f fptest.Format) {
+	if f != fptest.Float16 && f != fptest.BFloat16 {
+		fmt.Fprintln(os.Stderr, "narrowing needs -format float16 or bfloat16")
+		flag.Usage()
+		os.Exit(2)
+	}
+	fptest.ChainedNarrowing(fptest.Float64, fptest.Float32, f, func(lo, hi, x float64) {
+		fmt.Printf("[%b, %b] %s=%b (%v)\n", lo, hi, f.Name, x, x)
+	})
+}
+
+// ecmaScript prints the floats of the hardest cases of mode m (both
+// floats around the midpoint for Midpoints) as hexadecimal bits,
+// followed by their ECMAScript string.
+func ecmaScript(m fptest.Mode, digits int, prec uint, count int) {
+	if prec == 0 {
+		prec = defaultPrec(fptest.Float64, digits)
+	}
+	fptest.BestFirst(fptest.Float64, m, digits, prec, count, func(c fptest.HardCase) {
+		xs := []float64{c.X}
+		if m == fptest.Midpoints {
+			xs = append(xs, math.Nextafter(c.X, math.Inf(1)))
+		}
+		for _, x := range xs {
+			fmt.Printf("%016x %s\n", math.Float64bits(x), fptest.ECMAScriptString(x))
+		}
+	})
+}
+
+// check runs an external program answering queries on its
+// standard input (see fptest.Query), and prints the mismatches
+// and a report of mismatches by kind of query and exponent.
+func check(f fptest.Format, digits int, prec uint, count int, minimize bool, command []string) {
+	if len(command) == 0 {
+		log.Fatal("missing command: mktest check PROGRAM [ARGS...]")
+	}
+	if f != fptest.Float32 && f != fptest.Float64 {
+		fmt.Fprintln(os.Stderr, "check needs -format float32 or float64")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	e, err := fptest.StartExternal(command[0], command[1:]...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var r fptest.Report
+	report := func(q fptest.Query, reply string, ok bool) {
+		if !ok {
+			fmt.Printf("MISMATCH %s: got %s, want %s\n", q, reply, q.Want)
+		}
+		r.Add(q, ok)
+	}
+	checkCase := func(m fptest.Mode, c fptest.HardCase, fn func(fptest.Query, string, bool)) bool {
+		ok, err := e.CheckCase(f, m, c, fn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return ok
+	}
+	for _, m := range []fptest.Mode{fptest.Midpoints, fptest.HalfDecimals} {
+		var failed *fptest.HardCase
+		fptest.BestFirst(f, m, digits, prec, count, func(c fptest.HardCase) {
+			if !checkCase(m, c, report) && failed == nil {
+				failed = &c
+			}
+		})
+		if minimize && failed != nil {
+			rep := fptest.Minimize(f, m, *failed, digits, func(c fptest.HardCase) bool {
+				return checkCase(m, c, nil)
+			}, count)
+			fmt.Printf("REPRODUCER %s %d digits %de%d (e2=%d eps=%.3g precision=%d, %d checks)\n",
+				m, rep.Digits, rep.N, rep.K, rep.Exp, rep.Eps, rep.Precision, rep.Checks)
+			for _, q := range fptest.CaseQueries(f, m, rep.HardCase) {
+				fmt.Printf("  %s -> %s\n", q, q.Want)
+			}
+		}
+	}
+	if err := e.Close(); err != nil {
+		log.Print(err)
+	}
+	r.WriteTo(os.Stdout)
+	if r.Mismatches() > 0 {
+		os.Exit(1)
+	}
+}
+
+// explain prints the nearest decimals of floats given as decimal
+// or hexadecimal strings, and how hard they are.
+func explain(f fptest.Format, m fptest.Mode, digits int, prec uint, args []string) {
+	if len(args) == 0 {
+		log.Fatal("missing number: mktest explain NUMBER...")
+	}
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	for _, s := range args {
+		ex, err := fptest.ExplainString(f, m, s, digits, prec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("=== %s (%s %s) ===\n", s, f.Name, m)
+		fmt.Printf("x=%b = %x = %v frexp=%v×2^%d\n", ex.X, ex.X, ex.X, ex.Mant, ex.Exp)
+		for _, c := range ex.Cases {
+			fmt.Printf("digits=%d %de%d e2=%d eps=%+.6e (%.3f bits) enumerated at %d bits: %v\n",
+				c.Digits, c.N, c.K, c.Exp, c.Eps, c.Bits, prec, c.Enumerated)
+			a, b := c.Fraction.Fraction()
+			fmt.Printf("  path %d/%d: %s\n", a, b, c.Fraction.Path())
+			fmt.Printf("  target lower: %s\n", c.Lower.Path())
+			fmt.Printf("  target upper: %s\n", c.Upper.Path())
+		}
+	}
+}
+
+// spellings prints the syntactic variants of the hardest
+// decimals, with the expected result of parsing them.
+func spellings(f fptest.Format, digits int, prec uint, count int) {
+	set := fptest.StandardSpellings
+	switch *spelling {
+	case "standard":
+	case "all":
+		set = fptest.AllSpellings
+	default:
+		log.Fatalf("unknown spellings %q", *spelling)
+	}
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	fptest.BestFirst(f, fptest.Midpoints, digits, prec, count, func(c fptest.HardCase) {
+		want := f.Rounded(c, fptest.Midpoints, big.ToNearestEven).Float
+		for _, s := range fptest.Spell(c.N, c.K, set) {
+			fmt.Printf("%b %s\n", want, s)
+		}
+	})
+}
+
+// wideMidpoints lists float64 midpoints very close to
+// decimals with 20 to maxDigits digits.
+func wideMidpoints(maxDigits int, prec uint) {
+	for digits := 20; digits <= maxDigits; digits++ {
+		p := prec
+		if p == 0 {
+			p = uint(basePrec + 2*digits)
+		}
+		fmt.Println("===", digits, "digits ===")
+		show := func(x float64, n [2]uint64, k int) {
+			N := new(big.Int).SetUint64(n[0])
+			N.Lsh(N, 64).Or(N, new(big.Int).SetUint64(n[1]))
+			fmt.Printf("%b %se%d\n", x, N, k)
+		}
+		fptest.AlmostDecimalMidpoint128(fptest.Float64.MinExp, digits, 52, p, -1, true, show)
+		fptest.AlmostDecimalMidpoint128(fptest.Float64.MinExp, digits, 52, p, +1, true, show)
+		for e2 := fptest.Float64.MinExp; e2 <= fptest.Float64.MaxExp; e2++ {
+			fptest.AlmostDecimalMidpoint128(e2, digits, 53, p, -1, false, show)
+			fptest.AlmostDecimalMidpoint128(e2, digits, 53, p, +1, false, show)
+		}
+	}
+}
+
+// longDecimals prints long decimal strings around the hardest
+// midpoints, with the expected result of parsing them.
+func longDecimals(f fptest.Format, digits int, prec uint, count int) {
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	fptest.LongDecimals(f, digits, prec, count, []int{19, 40, 800}, func(c fptest.HardCase, s string, want float64) {
+		fmt.Printf("%b %s\n", want, s)
+	})
+}
+
+// fuzzCorpus writes the hardest cases as seed corpus files
+// of a fuzz test.
+func fuzzCorpus(f fptest.Format, m fptest.Mode, digits int, prec uint, count int) {
+	if *fuzzName == "" {
+		log.Fatal("missing fuzz test name (-fuzz)")
+	}
+	var typ fptest.SeedType
+	switch *seedType {
+	case "decimal":
+		typ = fptest.DecimalSeed
+	case "float":
+		typ = fptest.FloatSeed
+	case "bits":
+		typ = fptest.BitsSeed
+	default:
+		log.Fatalf("unknown seed type %q", *seedType)
+	}
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	w, err := fptest.NewCorpusWriter(*fuzzDir, *fuzzName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fptest.AddSeeds(w, f, m, typ, digits, prec, count)
+	if w.Err != nil {
+		log.Fatal(w.Err)
+	}
+	log.Printf("wrote corpus to %s", w.Dir)
+}
+
+// vectors writes the hardest cases to standard output as a source
+// file of test vectors for the language given by -lang.
+func vectors(f fptest.Format, m fptest.Mode, digits int, prec uint, count int) {
+	if prec == 0 {
+		prec = defaultPrec(f, digits)
+	}
+	v := fptest.NewVectorFile(f, m, digits, prec, count)
+	v.Command = "mktest " + strings.Join(os.Args[1:], " ")
+	var err error
+	switch *lang {
+	case "c":
+		err = v.WriteC(os.Stdout)
+	case "rust":
+		err = v.WriteRust(os.Stdout)
+	case "go":
+		err = v.WriteGo(os.Stdout, *pkg)
+	default:
+		log.Fatalf("unknown language %q", *lang)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// hardProducts lists decimal numbers which cannot be parsed
+// using only a truncated product by a power of ten.
+func hardProducts(digits int, width uint) {
+	count := 0
+	show := func(x float64, n uint64, k int) {
+		count++
+		fmt.Printf("count=%08d %de%d %b %.17e\n", count, n, k, x, x)
+	}
+	for k := -324 - digits; k <= 308; k++ {
+		fptest.AlmostRoundingProduct(k, digits, width, width-55, show)
+	}
+}
+
 // These numbers are hard to round correctly (down or up?).
 func hardFloats() {
 	count := 0
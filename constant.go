@@ -0,0 +1,112 @@
+package fptest
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// AlmostMidpointMultiple enumerates floats x of format f in [1, 2)
+// such that the exact product x × c is very close to a midpoint
+// between consecutive floats of format f, where c is a positive constant.
+//
+// direction = +1 will return products slightly above the midpoint
+// direction = 0  will return products exactly equal to the midpoint
+// direction = -1 will return products slightly below the midpoint
+//
+// Very close is interpreted as a relative difference less than
+// 1 / 2^precision, and the hardest cases come first. Unless c has
+// a small denominator, there are about 2^(2×MantBits-precision) cases,
+// so precision should be about 2×MantBits. The callback
+// receives x and the correctly rounded product y (ties to even).
+//
+// Since the rounding of x × c only depends on the mantissa of x,
+// the cases for other exponents are obtained by multiplying x and y
+// by a power of two, as long as both are normal numbers.
+func AlmostMidpointMultiple(c *big.Rat, f Format, precision uint, direction int,
+	fn func(x, y float64)) {
+	if c.Sign() <= 0 {
+		panic(fmt.Sprintf("constant %s is not positive", c.RatString()))
+	}
+	p := f.MantBits
+	// c is in [2^t, 2^(t+1)).
+	t := ilog2(c)
+	// For x = m×2^(1-p), the product is close to (M+1/2)×2^(1-p-s)
+	// where M/m is close to c×2^s, for s = -t-1 or -t. We look for
+	// fractions (2M+1)/m close to X = c×2^(s+1).
+	var walks []*constantWalk
+	for _, s := range []int{-t - 1, -t} {
+		num := new(big.Int).Set(c.Num())
+		den := new(big.Int).Set(c.Denom())
+		if s+1 >= 0 {
+			num.Lsh(num, uint(s+1))
+		} else {
+			den.Lsh(den, uint(-s-1))
+		}
+		// Products above the midpoint are such that
+		// (2M+1)/m is below X.
+		it := newRatIter(num, den, precision, -direction, p)
+		if !it.done {
+			walks = append(walks, &constantWalk{
+				num: num, den: den, x: new(big.Rat).SetFrac(num, den),
+				e: 1 - int(p) - s, it: it})
+		}
+	}
+	// Merge both walks by increasing distance to their X,
+	// so that the hardest cases of both come first.
+	for len(walks) > 0 {
+		i := 0
+		if len(walks) == 2 && walks[1].dist().Cmp(walks[0].dist()) < 0 {
+			i = 1
+		}
+		w := walks[i]
+		a, b := w.it.r.Fraction()
+		// If a is even, all multiples are even.
+		if a%2 == 1 {
+			multiples(a, b, p, false, func(a, m uint64) {
+				if a%2 == 0 || bits.Len64(a) != int(p)+1 || !within(a, m, w.num, w.den, precision) {
+					return
+				}
+				M := a / 2
+				if direction > 0 || direction == 0 && M%2 == 1 {
+					M++
+				}
+				fn(math.Ldexp(float64(m), 1-int(p)), math.Ldexp(float64(M), w.e))
+			})
+		}
+		w.it.next()
+		if w.it.done {
+			walks = append(walks[:i], walks[i+1:]...)
+		}
+	}
+}
+
+// A constantWalk walks the fractions (2M+1)/m close to X = num/den
+// for AlmostMidpointMultiple, where products are close to (M+1/2)×2^e.
+type constantWalk struct {
+	num, den *big.Int
+	x        *big.Rat
+	e        int
+	it       *ratIter
+}
+
+// dist returns the relative distance between the current
+// fraction and X.
+func (w *constantWalk) dist() *big.Rat {
+	d := w.it.r.BigRat()
+	d.Quo(d, w.x)
+	d.Sub(d, big.NewRat(1, 1))
+	return d.Abs(d)
+}
+
+// within returns whether a/b is within a relative difference
+// of 2^-precision of num/den. The Farey range given by ratRange
+// may contain a few fractions beyond that bound.
+func within(a, b uint64, num, den *big.Int, precision uint) bool {
+	y := new(big.Int).Mul(new(big.Int).SetUint64(b), num)
+	d := new(big.Int).Mul(new(big.Int).SetUint64(a), den)
+	d.Sub(d, y)
+	d.Abs(d)
+	return d.Lsh(d, precision).Cmp(y) < 0
+}
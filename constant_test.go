@@ -0,0 +1,104 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestAlmostMidpointMultiple(t *testing.T) {
+	// Compare with all mantissas of a 12-bit format.
+	f := Format{Name: "test12", MantBits: 12, MinExp: -100, MaxExp: 100}
+	const prec = 18
+	for _, c := range []*big.Rat{
+		big.NewRat(1, 3), big.NewRat(100, 1), big.NewRat(1, 100),
+		big.NewRat(355, 113*180), big.NewRat(2, 3), big.NewRat(7, 5), big.NewRat(104729, 65537),
+	} {
+		for _, dir := range []int{-1, 0, 1} {
+			found := make(map[float64]bool)
+			last := new(big.Rat)
+			AlmostMidpointMultiple(c, f, prec, dir, func(x, y float64) {
+				if found[x] {
+					t.Errorf("c=%s: duplicate x=%v", c.RatString(), x)
+				}
+				found[x] = true
+				// The hardest cases come first.
+				d := midpointDiff(f, new(big.Rat).Mul(new(big.Rat).SetFloat64(x), c))
+				d.Abs(d)
+				if d.Cmp(last) < 0 {
+					t.Errorf("c=%s dir=%d x=%v: diff %s after %s",
+						c.RatString(), dir, x, d.FloatString(10), last.FloatString(10))
+				}
+				last = d
+				if w := roundRat(f, new(big.Rat).Mul(new(big.Rat).SetFloat64(x), c)); y != w {
+					t.Errorf("c=%s x=%v: got y=%v, want %v", c.RatString(), x, y, w)
+				}
+			})
+			n := 0
+			for m := 1 << 11; m < 1<<12; m++ {
+				x := math.Ldexp(float64(m), -11)
+				prod := new(big.Rat).Mul(new(big.Rat).SetFloat64(x), c)
+				d := midpointDiff(f, prod)
+				want := false
+				switch dir {
+				case 0:
+					want = d.Sign() == 0
+				case 1:
+					want = d.Sign() > 0 && d.Cmp(big.NewRat(1, 1<<prec)) < 0
+				case -1:
+					want = d.Sign() < 0 && d.Cmp(big.NewRat(-1, 1<<prec)) > 0
+				}
+				if want {
+					n++
+				}
+				if want != found[x] {
+					t.Errorf("c=%s dir=%d x=%v: enumerated=%v, want %v (diff %s)",
+						c.RatString(), dir, x, found[x], want, d.FloatString(10))
+				}
+			}
+			t.Logf("c=%s dir=%d: %d cases", c.RatString(), dir, n)
+		}
+	}
+}
+
+// midpointDiff returns the relative difference between
+// a positive r and the nearest midpoint of format f.
+func midpointDiff(f Format, r *big.Rat) *big.Rat {
+	x, _ := r.Float64()
+	_, e := math.Frexp(x)
+	half := new(big.Rat).SetFloat64(math.Ldexp(1, e-int(f.MantBits)-1))
+	// The nearest odd multiple of half.
+	q := new(big.Rat).Quo(r, half)
+	k := new(big.Int).Quo(q.Num(), q.Denom())
+	k.SetBit(k, 0, 1)
+	mid := new(big.Rat).Mul(new(big.Rat).SetInt(k), half)
+	d := new(big.Rat).Sub(r, mid)
+	return d.Quo(d, mid)
+}
+
+// roundRat rounds a positive r to format f, ignoring exponent bounds.
+func roundRat(f Format, r *big.Rat) float64 {
+	x, _ := new(big.Float).SetPrec(f.MantBits).SetMode(big.ToNearestEven).SetRat(r).Float64()
+	return x
+}
+
+func TestAlmostMidpointMultipleFloat64(t *testing.T) {
+	// Degrees to radians.
+	c := big.NewRat(314159265358979, 180e14)
+	n := 0
+	for _, dir := range []int{-1, 1} {
+		AlmostMidpointMultiple(c, Float64, 100, dir, func(x, y float64) {
+			n++
+			if w := roundRat(Float64, new(big.Rat).Mul(new(big.Rat).SetFloat64(x), c)); y != w {
+				t.Errorf("x=%v: got y=%v, want %v", x, y, w)
+			}
+			if d := midpointDiff(Float64, new(big.Rat).Mul(new(big.Rat).SetFloat64(x), c)); d.Sign() != dir {
+				t.Errorf("x=%v: product on the wrong side (%s)", x, d.FloatString(30))
+			}
+		})
+	}
+	t.Logf("%d cases", n)
+	if n == 0 {
+		t.Errorf("no cases found")
+	}
+}
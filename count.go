@@ -0,0 +1,194 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// A Parity is a constraint on the fractions a/b
+// counted by CountRange.
+type Parity int
+
+const (
+	AnyParity      Parity = iota
+	OddNumerator          // a is odd, as in AlmostHalfDecimal
+	OddDenominator        // b is odd, as in AlmostDecimalMidpoint
+)
+
+// CountRange returns the number of fractions a/b (not necessarily
+// irreducible, with a > 0) very close to X = num/den, as walked
+// by WalkRange, where b has exactly nbits bits (at most nbits bits
+// if denormal is set) and satisfies the parity constraint.
+//
+// This is the number of cases returned by the enumerators.
+// The count is computed using floor sums over the bounds of the
+// Farey interval, in time logarithmic in its size. The result
+// saturates at math.MaxUint64.
+func CountRange(num, den *big.Int, precision uint, direction int, nbits uint,
+	denormal bool, parity Parity) uint64 {
+	r1, r2 := ratRange(num, den, precision, direction, nbits)
+	return newCounter(r1, r2, nbits, denormal, parity).total
+}
+
+// A counter counts fractions a/b in [r1, r2)
+// with constraints on a and b.
+type counter struct {
+	r1, r2     *Rat
+	bmin, bmax uint64 // included bounds for b
+	parity     Parity
+	below1     *big.Int // fractions below r1
+	total      uint64
+}
+
+func newCounter(r1, r2 *Rat, nbits uint, denormal bool, parity Parity) *counter {
+	c := &counter{r1: r1, r2: r2, parity: parity}
+	c.bmin = uint64(1) << (nbits - 1)
+	if denormal {
+		c.bmin = 1
+	}
+	c.bmax = ^uint64(0) >> (64 - nbits)
+	if !r1.Less(r2) {
+		return c
+	}
+	c.below1 = c.sumBelow(r1.Fraction())
+	count := c.sumBelow(r2.Fraction())
+	count.Sub(count, c.below1)
+	c.total = saturate(count)
+	return c
+}
+
+func saturate(n *big.Int) uint64 {
+	if !n.IsUint64() {
+		return math.MaxUint64
+	}
+	return n.Uint64()
+}
+
+// below returns the number of fractions in [r1, p/q),
+// where q = 0 means infinity.
+func (c *counter) below(p, q uint64) uint64 {
+	switch {
+	case c.total == 0:
+		return 0
+	case q == 0, !fracLess(p, q, c.r2.a, c.r2.c):
+		return c.total
+	}
+	n := c.sumBelow(p, q)
+	if n.Cmp(c.below1) <= 0 {
+		return 0
+	}
+	return saturate(n.Sub(n, c.below1))
+}
+
+// fracLess returns whether p/q < r/s.
+func fracLess(p, q, r, s uint64) bool {
+	x1, x0 := bits.Mul64(p, s)
+	y1, y0 := bits.Mul64(r, q)
+	return x1 < y1 || (x1 == y1 && x0 < y0)
+}
+
+// sumBelow returns the number of fractions a/b < p/q with a > 0.
+func (c *counter) sumBelow(p, q uint64) *big.Int {
+	// The numerators a such that a/b < p/q are the integers
+	// in [0, ceil(b×p/q)).
+	n := sumCeil(p, q, c.bmin, c.bmax, c.parity)
+	if p > 0 && c.parity != OddNumerator {
+		// The numerator 0 is not counted.
+		n.Sub(n, new(big.Int).SetUint64(c.countB()))
+	}
+	return n
+}
+
+// countB returns the number of possible denominators.
+func (c *counter) countB() uint64 {
+	if c.parity == OddDenominator {
+		return (c.bmax+1)/2 - c.bmin/2
+	}
+	return c.bmax - c.bmin + 1
+}
+
+// CountDecimalMidpoint returns the number of cases
+// enumerated by AlmostDecimalMidpoint.
+func CountDecimalMidpoint(e2 int, digits int, mantbits, precision uint, direction int, denormal bool) uint64 {
+	return midpointTarget(e2, digits, mantbits, denormal).count(precision, -direction)
+}
+
+// CountHalfDecimal returns the number of cases
+// enumerated by AlmostHalfDecimal.
+func CountHalfDecimal(e2 int, digits int, mantbits, precision uint, direction int, denormal bool) uint64 {
+	return halfDecimalTarget(e2, digits, mantbits, denormal).count(precision, -direction)
+}
+
+// CountDecimal returns the number of cases
+// enumerated by AlmostDecimal.
+func CountDecimal(e2 int, digits int, mantbits, precision uint, direction int, denormal bool) uint64 {
+	return decimalTarget(e2, digits, mantbits, denormal).count(precision, -direction)
+}
+
+func (t *target) count(precision uint, direction int) uint64 {
+	if t == nil {
+		return 0
+	}
+	return CountRange(t.num, t.den, precision, direction, t.nbits, t.denormal, t.parity)
+}
+
+// sumCeil returns the sum of g(ceil(b×p/q)) for bmin <= b <= bmax,
+// where g(a) = a, or the number of odd integers below a
+// if parity is OddNumerator. If parity is OddDenominator,
+// only odd b are summed.
+func sumCeil(p, q uint64, bmin, bmax uint64, parity Parity) *big.Int {
+	P := new(big.Int).SetUint64(p)
+	Q := new(big.Int).SetUint64(q)
+	// ceil(b×p/q) = floor((b×p + q-1) / q)
+	C := new(big.Int).Sub(Q, big.NewInt(1))
+	B0 := new(big.Int).SetUint64(bmin)
+	n := new(big.Int).SetUint64(bmax - bmin + 1)
+	switch parity {
+	case OddNumerator:
+		// floor(floor(x)/2) = floor(x/2)
+		Q.Lsh(Q, 1)
+	case OddDenominator:
+		// b = 2j+1 for bmin/2 <= j <= (bmax-1)/2
+		j0 := bmin / 2
+		n.SetUint64((bmax-1)/2 - j0 + 1)
+		B0.SetUint64(j0)
+		C.Add(C, P)
+		P.Lsh(P, 1)
+	}
+	// Sum over i from 0 to n-1 of floor((P×(B0+i) + C) / Q)
+	C.Add(C, B0.Mul(B0, P))
+	return floorSum(n, Q, P, C)
+}
+
+// floorSum returns the sum of floor((a×i + b) / m) for 0 <= i < n,
+// where all arguments are non-negative.
+func floorSum(n, m, a, b *big.Int) *big.Int {
+	n, m = new(big.Int).Set(n), new(big.Int).Set(m)
+	a, b = new(big.Int).Set(a), new(big.Int).Set(b)
+	sum := new(big.Int)
+	q, t := new(big.Int), new(big.Int)
+	for n.Sign() > 0 {
+		if a.Cmp(m) >= 0 {
+			// sum += n(n-1)/2 × (a/m)
+			q.DivMod(a, m, a)
+			t.Sub(n, big.NewInt(1))
+			t.Mul(t, n)
+			t.Rsh(t, 1)
+			sum.Add(sum, t.Mul(t, q))
+		}
+		if b.Cmp(m) >= 0 {
+			q.DivMod(b, m, b)
+			sum.Add(sum, t.Mul(n, q))
+		}
+		ymax := new(big.Int).Mul(a, n)
+		ymax.Add(ymax, b)
+		if ymax.Cmp(m) < 0 {
+			break
+		}
+		// Count lattice points by swapping axes.
+		n.DivMod(ymax, m, b)
+		m, a = a, m
+	}
+	return sum
+}
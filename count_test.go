@@ -0,0 +1,80 @@
+package fptest
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestFloorSum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		n, m := rnd.Int63n(100), rnd.Int63n(100)+1
+		a, b := rnd.Int63n(1000), rnd.Int63n(1000)
+		var want int64
+		for j := int64(0); j < n; j++ {
+			want += (a*j + b) / m
+		}
+		got := floorSum(big.NewInt(n), big.NewInt(m), big.NewInt(a), big.NewInt(b))
+		if got.Int64() != want {
+			t.Errorf("floorSum(%d, %d, %d, %d) = %s, want %d", n, m, a, b, got, want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	type enumFunc func(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+		f func(x float64, n uint64, k int))
+	type countFunc func(e2 int, digits int, mantbits, precision uint, direction int, denormal bool) uint64
+	modes := []struct {
+		name  string
+		enum  enumFunc
+		count countFunc
+	}{
+		{"midpoints", AlmostDecimalMidpoint, CountDecimalMidpoint},
+		{"halfdecimals", AlmostHalfDecimal, CountHalfDecimal},
+		{"nearfloats", AlmostDecimal, CountDecimal},
+	}
+	total := uint64(0)
+	for _, m := range modes {
+		for _, f := range []Format{Float32, Float64} {
+			for _, e2 := range []int{f.MinExp, f.MinExp + 40, -30, -2, 0, 5, 60, f.MaxExp} {
+				for digits := 1; digits <= 12; digits += 3 {
+					for _, dir := range []int{-1, 0, 1} {
+						for _, denormal := range []bool{false, true} {
+							if denormal && e2 != f.MinExp {
+								continue
+							}
+							mantbits := f.MantBits
+							if denormal {
+								mantbits--
+							}
+							prec := uint(2*digits) + 2*mantbits - 20
+							want := m.count(e2, digits, mantbits, prec, dir, denormal)
+							if want > 1000000 {
+								// Too many exact cases to enumerate.
+								continue
+							}
+							var got uint64
+							m.enum(e2, digits, mantbits, prec, dir, denormal,
+								func(float64, uint64, int) { got++ })
+							total += got
+							if got != want {
+								t.Errorf("%s %s e2=%d digits=%d dir=%d denormal=%v: enumerated %d, counted %d",
+									m.name, f.Name, e2, digits, dir, denormal, got, want)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	t.Logf("%d cases enumerated", total)
+
+	// Large counts.
+	n := CountDecimalMidpoint(600, 17, 53, 50, +1, false)
+	t.Logf("count(float64, e2=600, 17 digits, 2^-50) = %d", n)
+	if n < 1e15 {
+		t.Errorf("count is too small")
+	}
+}
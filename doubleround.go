@@ -0,0 +1,179 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+)
+
+// AlmostDoubleRounding enumerates decimal numbers n×10^k, near
+// midpoints of format narrow with exponent e2, which are incorrectly
+// rounded when they are first rounded to the wider format wide
+// (as ParseFloat(s, 64) followed by a float32 conversion).
+//
+// Since midpoints of the narrow format are numbers of the wide format,
+// the first rounding can never cross a midpoint, but decimals within
+// half an ulp of the wide format of a midpoint are rounded to the
+// midpoint itself, and the second rounding then breaks the tie
+// to even, which is wrong for decimals on the other side.
+//
+// The decimals are the cases of AlmostDecimalMidpoint with a relative
+// precision of wide.MantBits bits. The callback receives the correctly
+// rounded number x of the narrow format: the double rounded result is
+// the other neighbour of the midpoint.
+//
+// There are about 2^(narrow.MantBits-wide.MantBits)×10^digits cases
+// per exponent: from float64 to float32, they become very numerous
+// beyond 12 digits.
+func AlmostDoubleRounding(wide, narrow Format, e2 int, digits int, denormal bool,
+	f func(x float64, n uint64, k int)) {
+	t := narrow.target(Midpoints, e2, digits, denormal)
+	if t == nil {
+		return
+	}
+	for _, dir := range []int{-1, +1} {
+		t.walk(wide.MantBits, dir, func(x float64, n uint64, k int) {
+			d := decimalRat(n, k)
+			if y := narrow.round(d); y != narrow.round(new(big.Rat).SetFloat64(wide.round(d))) {
+				f(y, n, k)
+			}
+		})
+	}
+}
+
+// decimalRat returns n×10^k as a big.Rat.
+func decimalRat(n uint64, k int) *big.Rat {
+	d := new(big.Rat).SetInt(new(big.Int).SetUint64(n))
+	p := new(big.Rat).SetInt(pow10Big(abs(k)))
+	if k >= 0 {
+		return d.Mul(d, p)
+	}
+	return d.Quo(d, p)
+}
+
+// round returns the number of format f nearest to a non-negative r,
+// with ties to even. Numbers above the largest finite number
+// of the format round to infinity.
+func (f Format) round(r *big.Rat) float64 {
+	return f.roundMode(r, big.ToNearestEven)
+}
+
+// roundMode is like round, using the rounding mode rm. Numbers above
+// the largest finite number round to infinity only if rm rounds up.
+func (f Format) roundMode(r *big.Rat, rm big.RoundingMode) float64 {
+	if r.Sign() == 0 {
+		return 0
+	}
+	// r is in [2^t, 2^(t+1)).
+	t := ilog2(r)
+	e := t - int(f.MantBits) + 1
+	if e < f.MinExp {
+		e = f.MinExp
+	}
+	// m = r / 2^e rounded according to rm.
+	q := new(big.Rat)
+	if e > 0 {
+		q.Quo(r, new(big.Rat).SetInt(pow2Big(uint(e))))
+	} else {
+		q.Mul(r, new(big.Rat).SetInt(pow2Big(uint(-e))))
+	}
+	m := roundInt(q.Num(), q.Denom(), rm)
+	if m.BitLen() > int(f.MantBits) {
+		m.Rsh(m, 1)
+		e++
+	}
+	if e > f.MaxExp {
+		switch rm {
+		case big.ToZero, big.ToNegativeInf:
+			return math.Ldexp(float64(uint64(1)<<f.MantBits-1), f.MaxExp)
+		}
+		return math.Inf(1)
+	}
+	return math.Ldexp(float64(m.Uint64()), e)
+}
+
+// roundInt returns the quotient of non-negative integers num/den
+// rounded to an integer according to rm.
+func roundInt(num, den *big.Int, rm big.RoundingMode) *big.Int {
+	m, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return m
+	}
+	up := false
+	switch rm {
+	case big.ToNearestEven, big.ToNearestAway:
+		c := rem.Lsh(rem, 1).Cmp(den)
+		up = c > 0 || c == 0 && (rm == big.ToNearestAway || m.Bit(0) == 1)
+	case big.AwayFromZero, big.ToPositiveInf:
+		up = true
+	}
+	if up {
+		m.Add(m, big.NewInt(1))
+	}
+	return m
+}
+
+// ilog2 returns the integer t such that 2^t <= r < 2^(t+1),
+// for a positive r.
+func ilog2(r *big.Rat) int {
+	n, d := r.Num(), r.Denom()
+	t := n.BitLen() - d.BitLen()
+	if t >= 0 && n.Cmp(new(big.Int).Lsh(d, uint(t))) < 0 ||
+		t < 0 && new(big.Int).Lsh(n, uint(-t)).Cmp(d) < 0 {
+		t--
+	}
+	return t
+}
+
+// ChainedNarrowing enumerates the numbers of format wide which are
+// converted incorrectly to format narrow when they are first rounded
+// to an intermediate format mid (as float64 → float32 → float16).
+// The formats must have decreasing precisions, and the numbers of
+// format narrow must be numbers of format mid.
+//
+// As in AlmostDoubleRounding, these are the numbers rounded to
+// a midpoint of format narrow by the first conversion, on the side
+// opposite to the tie-breaking direction. Since all formats are binary,
+// they form an interval around each midpoint, of the width of an ulp
+// of format mid: f is called, by increasing values, with the bounds
+// lo <= hi of each interval of positive numbers and the correctly
+// rounded result x of the direct conversion.
+//
+// Unlike the decimal enumerators, this does not walk the Stern-Brocot
+// tree: between binary formats, the ratio of the scales is a power of
+// two, and the intervals have a closed form.
+func ChainedNarrowing(wide, mid, narrow Format, f func(lo, hi, x float64)) {
+	if !(wide.MantBits > mid.MantBits && mid.MantBits > narrow.MantBits) {
+		panic("formats must have decreasing precisions")
+	}
+	p := narrow.MantBits
+	for e := narrow.MinExp; e <= narrow.MaxExp; e++ {
+		// Denormals have exponent MinExp and smaller mantissas.
+		m0 := uint64(1) << (p - 1)
+		if e == narrow.MinExp {
+			m0 = 0
+		}
+		for M := m0; M < 1<<p; M++ {
+			// The midpoint (2M+1)×2^(e-1) is rounded
+			// to the even neighbour.
+			mu := math.Ldexp(float64(2*M+1), e-1)
+			half := mid.ulp(mu) / 2
+			if M%2 == 0 {
+				// Ties are rounded down, numbers above should round up.
+				f(mu+wide.ulp(mu), mu+half, math.Ldexp(float64(M+1), e))
+			} else {
+				f(mu-half, mu-wide.ulp(mu), math.Ldexp(float64(M), e))
+			}
+		}
+	}
+}
+
+// ulp returns the unit in the last place of numbers of
+// format f around a positive x, which must not be a power of two.
+func (f Format) ulp(x float64) float64 {
+	_, exp := math.Frexp(x)
+	e := exp - int(f.MantBits)
+	if e < f.MinExp {
+		e = f.MinExp
+	}
+	return math.Ldexp(1, e)
+}
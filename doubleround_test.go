@@ -0,0 +1,103 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestFormatRound(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		// Random fractions across the exponent range, including denormals.
+		num := new(big.Int).Rand(rnd, new(big.Int).Lsh(big.NewInt(1), uint(1+rnd.Intn(200))))
+		den := new(big.Int).Rand(rnd, new(big.Int).Lsh(big.NewInt(1), uint(1+rnd.Intn(200))))
+		if num.Sign() == 0 || den.Sign() == 0 {
+			continue
+		}
+		r := new(big.Rat).SetFrac(num, den)
+		switch rnd.Intn(3) {
+		case 0:
+			r.Mul(r, new(big.Rat).SetFloat64(math.Ldexp(1, -1000-rnd.Intn(100))))
+		case 1:
+			r.Mul(r, new(big.Rat).SetFloat64(math.Ldexp(1, -130-rnd.Intn(40))))
+		}
+		x64, _ := r.Float64()
+		if y := Float64.round(r); y != x64 {
+			t.Errorf("%s: got %b, want %b", r.FloatString(20), y, x64)
+		}
+		x32, _ := r.Float32()
+		if y := Float32.round(r); y != float64(x32) {
+			t.Errorf("%s: got %b, want %b", r.FloatString(20), y, x32)
+		}
+	}
+	// Ties to even.
+	for _, x := range []float64{1 + math.Ldexp(1, -24), 1 + math.Ldexp(3, -24), math.Ldexp(1, -150), math.Ldexp(3, -150)} {
+		want := float64(float32(x))
+		if y := Float32.round(new(big.Rat).SetFloat64(x)); y != want {
+			t.Errorf("%b: got %b, want %b", x, y, want)
+		}
+	}
+}
+
+func TestAlmostDoubleRounding(t *testing.T) {
+	count := 0
+	check := func(x float64, n uint64, k int) {
+		count++
+		d := decimalRat(n, k)
+		want, _ := d.Float32()
+		if float64(want) != x {
+			t.Errorf("%de%d: got %b, want %b", n, k, x, want)
+		}
+		x64, _ := d.Float64()
+		if float32(x64) == want {
+			t.Errorf("%de%d: not a double rounding case", n, k)
+		}
+	}
+	for digits := 9; digits <= 11; digits++ {
+		AlmostDoubleRounding(Float64, Float32, Float32.MinExp, digits, true, check)
+		for e2 := Float32.MinExp; e2 <= Float32.MaxExp; e2++ {
+			AlmostDoubleRounding(Float64, Float32, e2, digits, false, check)
+		}
+	}
+	t.Logf("%d cases", count)
+	if count == 0 {
+		t.Errorf("no double rounding cases")
+	}
+}
+
+func TestChainedNarrowing(t *testing.T) {
+	for _, narrow := range []Format{Float16, BFloat16} {
+		count := 0
+		last := 0.0
+		ChainedNarrowing(Float64, Float32, narrow, func(lo, hi, x float64) {
+			count++
+			if lo > hi || lo <= last {
+				t.Errorf("%s: invalid interval [%b, %b]", narrow.Name, lo, hi)
+			}
+			last = hi
+			below := math.Nextafter(lo, 0)
+			above := math.Nextafter(hi, math.Inf(1))
+			for _, y := range []float64{below, lo, (lo + hi) / 2, hi, above} {
+				r := new(big.Rat).SetFloat64(y)
+				direct := narrow.round(r)
+				chained := narrow.round(new(big.Rat).SetFloat64(float64(float32(y))))
+				inside := y >= lo && y <= hi
+				if inside && direct != x {
+					t.Errorf("%s: %b rounds to %b, expected %b", narrow.Name, y, direct, x)
+				}
+				if inside != (direct != chained) {
+					t.Errorf("%s: %b in [%b, %b] is %v, but direct=%b chained=%b",
+						narrow.Name, y, lo, hi, inside, direct, chained)
+				}
+			}
+		})
+		// One interval per midpoint.
+		want := (narrow.MaxExp - narrow.MinExp + 2) << (narrow.MantBits - 1)
+		if count != want {
+			t.Errorf("%s: %d intervals, expected %d", narrow.Name, count, want)
+		}
+		t.Logf("%s: %d intervals", narrow.Name, count)
+	}
+}
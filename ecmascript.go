@@ -0,0 +1,107 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ECMAScriptString returns the result of Number::toString(x) as
+// specified by ECMAScript (Number.prototype.toString with radix 10).
+//
+// The digits are computed exactly, without strconv: for increasing
+// numbers of digits k, the decimals with k significant digits around x
+// are tried, nearest first (ties to even), until one parses back to x.
+// This is the shortest representation, and among those, the closest
+// to x, as required by the specification.
+//
+// The decimal exponent n (such that x = 0.digits × 10^n) selects
+// the notation: integers up to 21 digits, fixed notation down to
+// 1e-6, otherwise exponential notation with an explicit sign.
+func ECMAScriptString(x float64) string {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case x == 0:
+		return "0"
+	case x < 0:
+		return "-" + ECMAScriptString(-x)
+	case math.IsInf(x, 1):
+		return "Infinity"
+	}
+	digits, n := exactShortest(Float64, x)
+	k := len(digits)
+	switch {
+	case k <= n && n <= 21:
+		return digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return "0." + strings.Repeat("0", -n) + digits
+	}
+	s := digits[:1]
+	if k > 1 {
+		s += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return s + "e+" + strconv.Itoa(n-1)
+	}
+	return s + "e-" + strconv.Itoa(1-n)
+}
+
+// exactShortest returns the shortest digits of a positive float x
+// of format f and the exponent n such that x ≈ 0.digits × 10^n.
+func exactShortest(f Format, x float64) (digits string, n int) {
+	r := new(big.Rat).SetFloat64(x)
+	e := decimalExp(r)
+	for k := 1; ; k++ {
+		// The candidates are the k-digit integers s0 = floor(x / 10^(e-k))
+		// and s0+1 (which may be 10^k). The nearest one is tried first,
+		// but near a power of two, the interval of decimals parsing to x
+		// is asymmetric and only the farthest one may be valid.
+		unit := pow10Rat(e - k)
+		q := new(big.Rat).Quo(r, unit)
+		s0, rem := new(big.Int).QuoRem(q.Num(), q.Denom(), new(big.Int))
+		s1 := new(big.Int).Add(s0, big.NewInt(1))
+		if rem.Sign() == 0 {
+			s1 = s0
+		}
+		cands := []*big.Int{s0, s1}
+		if c := rem.Lsh(rem, 1).Cmp(q.Denom()); c > 0 || c == 0 && s0.Bit(0) == 1 {
+			cands[0], cands[1] = s1, s0
+		}
+		for _, s := range cands {
+			d := new(big.Rat).Mul(new(big.Rat).SetInt(s), unit)
+			if f.round(d) == x {
+				str := s.String()
+				// If s is 10^k, the exponent is e+1.
+				return strings.TrimRight(str, "0"), e + len(str) - k
+			}
+		}
+	}
+}
+
+// decimalExp returns the exponent e such that
+// the positive r is in [10^(e-1), 10^e).
+func decimalExp(r *big.Rat) int {
+	f, _ := r.Float64()
+	e := int(math.Floor(math.Log10(f))) + 1
+	for {
+		if r.Cmp(pow10Rat(e-1)) < 0 {
+			e--
+		} else if r.Cmp(pow10Rat(e)) >= 0 {
+			e++
+		} else {
+			return e
+		}
+	}
+}
+
+func pow10Rat(e int) *big.Rat {
+	p := new(big.Rat).SetInt(pow10Big(abs(e)))
+	if e < 0 {
+		p.Inv(p)
+	}
+	return p
+}
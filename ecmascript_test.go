@@ -0,0 +1,67 @@
+package fptest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestECMAScriptString(t *testing.T) {
+	for _, c := range []struct {
+		x    float64
+		want string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{100, "100"},
+		{123e18, "123000000000000000000"},
+		{1e21, "1e+21"},
+		{1.5e21, "1.5e+21"},
+		{0.000001, "0.000001"},
+		{1.5e-7, "1.5e-7"},
+		{0.1, "0.1"},
+		{1.0 / 3, "0.3333333333333333"},
+		{9007199254740992, "9007199254740992"},
+		{math.MaxFloat64, "1.7976931348623157e+308"},
+		{5e-324, "5e-324"},
+		{math.SmallestNonzeroFloat64 * 2, "1e-323"},
+		{math.Ldexp(1, -1074+52), "2.2250738585072014e-308"},
+		// Powers of two where the closest decimal is below x but not valid.
+		{math.Ldexp(1, 1000), "1.0715086071862673e+301"},
+		{math.Ldexp(1, -1000), "9.332636185032189e-302"},
+	} {
+		if got := ECMAScriptString(c.x); got != c.want {
+			t.Errorf("ECMAScriptString(%v) = %s, want %s", c.x, got, c.want)
+		}
+	}
+}
+
+func TestECMAScriptStringRandom(t *testing.T) {
+	// ECMAScript conversion and encoding/json only differ
+	// on special values.
+	rnd := rand.New(rand.NewSource(1))
+	check := func(x float64) {
+		n, k := shortestDecimal(x, 64)
+		if got, want := ECMAScriptString(x), FormatJSON(n, k); got != want {
+			t.Errorf("ECMAScriptString(%b) = %s, want %s", x, got, want)
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		x := math.Float64frombits(rnd.Uint64() &^ (1 << 63))
+		if !math.IsInf(x, 0) && !math.IsNaN(x) {
+			check(x)
+		}
+		// Powers of two have asymmetric rounding intervals.
+		check(math.Ldexp(1, rnd.Intn(2098)-1074))
+	}
+	// Hard cases.
+	BestFirst(Float64, Midpoints, 17, 112, 500, func(c HardCase) {
+		check(c.X)
+		check(Float64.next(c.X))
+	})
+}
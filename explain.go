@@ -0,0 +1,269 @@
+package fptest
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// An Explanation describes a float as seen by the enumerations
+// of hard cases of a format and mode: for each number of digits,
+// the nearest decimal and how hard it is.
+type Explanation struct {
+	// X is the float (for HalfDecimals and NearFloats) or the float
+	// below the midpoint (for Midpoints).
+	X float64
+	// Mant and Exp are the results of math.Frexp(X).
+	Mant float64
+	Exp  int
+	// Cases lists the nearest decimals by increasing number of digits.
+	Cases []Explained
+}
+
+// An Explained is the hard case given by the decimal nearest
+// to a binary number (the midpoint above X, or X).
+type Explained struct {
+	HardCase
+	Digits   int
+	Denormal bool
+	// ExactEps is the exact value of Eps, and Bits is -log2|Eps|,
+	// +Inf if the decimal is exactly the binary number.
+	ExactEps *big.Rat
+	Bits     float64
+	// Fraction is the fraction a/b (in lowest terms) walked by the
+	// enumeration, where a is N (or 2N+1 for HalfDecimals) and b is
+	// the mantissa of X (or the mantissa of the midpoint).
+	Fraction *Rat
+	// Lower and Upper are the fractions nearest to the target ratio
+	// of the enumeration (see NewRatFromBig), with denominators of
+	// the same size as b. Hard cases share a long prefix of their
+	// Stern-Brocot path with them.
+	Lower, Upper *Rat
+	// Enumerated is whether the case is listed by BestFirst at the
+	// given precision, for the number of digits (Digits or Digits+1)
+	// whose decimal exponent is K.
+	Enumerated bool
+}
+
+// Explain describes a positive float x of format f for the enumeration
+// of mode m: for each number of digits up to maxDigits (at most 19),
+// it finds the decimal with that many digits nearest to x (or to the
+// midpoint above x), its relative difference and whether it is one
+// of the hard cases enumerated at the given precision. Decimals
+// whose numerator does not fit in 64 bits are absent.
+func Explain(f Format, m Mode, x float64, maxDigits int, precision uint) Explanation {
+	frac, exp := math.Frexp(x)
+	ex := Explanation{X: x, Mant: frac, Exp: exp}
+	e2 := exp - int(f.MantBits)
+	denormal := e2 < f.MinExp
+	if denormal {
+		e2 = f.MinExp
+	}
+	mant := uint64(math.Ldexp(x, -e2))
+	for digits := 1; digits <= maxDigits; digits++ {
+		t := f.explainTarget(m, e2, mant, digits, denormal)
+		c, ok := t.explain(mant, precision)
+		if !ok {
+			continue
+		}
+		c.Digits = digits
+		// The enumeration uses the decimal exponent of digits
+		// or digits+1, depending on the position of x in its binade.
+		c.Enumerated = c.Enumerated && f.enumeratesExp(m, e2, t.exp10, denormal)
+		ex.Cases = append(ex.Cases, c)
+	}
+	return ex
+}
+
+// explainTarget returns the target of mode m for the float with
+// mantissa mant and exponent e2, with the decimal exponent k such
+// that the nearest decimal has the given number of digits.
+func (f Format) explainTarget(m Mode, e2 int, mant uint64, digits int, denormal bool) *target {
+	nbits := f.MantBits
+	if denormal {
+		nbits--
+	}
+	// The binary number is b×2^e.
+	t := &target{nbits: nbits, denormal: denormal, exp2: e2}
+	b, e := mant, e2
+	// The decimal is a×10^k for a/b close to 2^(e+s)/10^k.
+	s := 0
+	switch m {
+	case Midpoints:
+		t.parity = OddDenominator
+		t.nbits++
+		b, e = 2*mant+1, e2-1
+	case HalfDecimals:
+		t.parity = OddNumerator
+		s = 1
+	case NearFloats:
+		t.parity = AnyParity
+	default:
+		panic("invalid mode")
+	}
+	// 10^(k+digits-1) <= b×2^e < 10^(k+digits)
+	v := new(big.Rat).SetInt(new(big.Int).SetUint64(b))
+	if e >= 0 {
+		v.Num().Lsh(v.Num(), uint(e))
+	} else {
+		v.SetFrac(v.Num(), pow2Big(uint(-e)))
+	}
+	k := int(math.Floor((math.Log2(float64(b))+float64(e))*log2overlog10)) - digits + 1
+	for ratPow10Cmp(v, k+digits) >= 0 {
+		k++
+	}
+	for ratPow10Cmp(v, k+digits-1) < 0 {
+		k--
+	}
+	t.exp10 = k
+	t.num, t.den = big.NewInt(1), big.NewInt(1)
+	if e+s >= 0 {
+		t.num.Lsh(t.num, uint(e+s))
+	} else {
+		t.den.Lsh(t.den, uint(-e-s))
+	}
+	if k >= 0 {
+		t.den.Mul(t.den, pow10Big(k))
+	} else {
+		t.num.Mul(t.num, pow10Big(-k))
+	}
+	switch m {
+	case Midpoints:
+		t.emit = func(a, b uint64, f func(x float64, n uint64, k int)) {
+			f(math.Ldexp(float64(b/2), e2), a, k)
+		}
+	case HalfDecimals:
+		t.emit = func(a, b uint64, f func(x float64, n uint64, k int)) {
+			f(math.Ldexp(float64(b), e2), a/2, k)
+		}
+	case NearFloats:
+		t.emit = func(a, b uint64, f func(x float64, n uint64, k int)) {
+			f(math.Ldexp(float64(b), e2), a, k)
+		}
+	}
+	return t
+}
+
+// ratPow10Cmp compares v and 10^k.
+func ratPow10Cmp(v *big.Rat, k int) int {
+	p := new(big.Rat)
+	if k >= 0 {
+		p.SetInt(pow10Big(k))
+	} else {
+		p.SetFrac(big.NewInt(1), pow10Big(-k))
+	}
+	return v.Cmp(p)
+}
+
+// enumeratesExp returns whether the enumeration of mode m for
+// exponent e2 uses the decimal exponent k for some number of digits.
+// Its target is then the same as the one of explainTarget.
+func (f Format) enumeratesExp(m Mode, e2, k int, denormal bool) bool {
+	for digits := 1; digits <= 20; digits++ {
+		if t := f.target(m, e2, digits, denormal); t != nil && t.exp10 == k {
+			return true
+		}
+	}
+	return false
+}
+
+// explain returns the case of t for the float with
+// mantissa mant, if the nearest decimal is positive and
+// fits in 64 bits.
+func (t *target) explain(mant uint64, precision uint) (Explained, bool) {
+	b := mant
+	if t.parity == OddDenominator {
+		// The midpoint.
+		b = 2*mant + 1
+	}
+	bb := new(big.Int).SetUint64(b)
+	// a = b×num/den, rounded to the nearest (odd) integer.
+	p := new(big.Int).Mul(bb, t.num)
+	var a *big.Int
+	switch {
+	case t.parity == OddNumerator && p.Cmp(t.den) < 0:
+		a = big.NewInt(1)
+	case t.parity == OddNumerator:
+		p.Sub(p, t.den)
+		a = roundInt(p, new(big.Int).Lsh(t.den, 1), big.ToNearestEven)
+		a.Lsh(a, 1).Add(a, big.NewInt(1))
+	default:
+		a = roundInt(p, t.den, big.ToNearestEven)
+	}
+	if a.Sign() <= 0 || a.BitLen() > 64 {
+		return Explained{}, false
+	}
+	var c Explained
+	c.Denormal = t.denormal
+	c.Exp = t.exp2
+	c.K = t.exp10
+	c.N = a.Uint64()
+	t.emit(a.Uint64(), b, func(x float64, n uint64, k int) {
+		c.X, c.N = x, n
+	})
+	// eps = a×den / (b×num) - 1
+	c.ExactEps = new(big.Rat).SetFrac(
+		new(big.Int).Mul(a, t.den),
+		new(big.Int).Mul(bb, t.num))
+	c.ExactEps.Sub(c.ExactEps, big.NewRat(1, 1))
+	c.Eps, _ = c.ExactEps.Float64()
+	c.Bits = math.Inf(1)
+	if c.Eps != 0 {
+		c.Bits = -math.Log2(math.Abs(c.Eps))
+	}
+	c.Fraction = exactRat(a.Uint64(), b, t.nbits)
+	c.Lower, c.Upper = NewRatFromBig(t.num, t.den, t.nbits)
+	c.Enumerated = t.enumerates(a.Uint64(), b, c.ExactEps, precision)
+	return c, true
+}
+
+// enumerates returns whether the streams of t at the given
+// precision include the fraction a/b at relative difference eps.
+// They contain every fraction strictly within 2^-precision of X
+// whose reduced denominator has at most nbits bits, and its
+// multiples with the bit length of mantissas (see multiples).
+func (t *target) enumerates(a, b uint64, eps *big.Rat, precision uint) bool {
+	if eps.Sign() == 0 {
+		return false
+	}
+	d := new(big.Rat).Abs(eps)
+	if d.Cmp(new(big.Rat).SetFrac(big.NewInt(1), pow2Big(precision))) >= 0 {
+		return false
+	}
+	if n := bits.Len64(b); n > int(t.nbits) || !t.denormal && n != int(t.nbits) {
+		return false
+	}
+	switch t.parity {
+	case OddNumerator:
+		return a%2 == 1
+	case OddDenominator:
+		return b%2 == 1
+	}
+	return true
+}
+
+// ExplainString is like Explain for a float given as a decimal or
+// hexadecimal string, such as "9007199254740993e-3" or "0x1.23p+456".
+// The string is first rounded to format f. For Midpoints, the float
+// explained is the one below the midpoint nearest to the value, so
+// that the decimals of the explanation are close to the value.
+func ExplainString(f Format, m Mode, s string, maxDigits int, precision uint) (Explanation, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Explanation{}, fmt.Errorf("invalid number %q", s)
+	}
+	if r.Sign() <= 0 {
+		return Explanation{}, fmt.Errorf("%s is not positive", s)
+	}
+	x := f.round(r)
+	if m == Midpoints {
+		// The midpoint above the float below r is nearest to r.
+		x = f.roundMode(r, big.ToZero)
+	}
+	max := new(big.Rat).SetInt(pow2Big(uint(f.MaxExp + int(f.MantBits))))
+	if x == 0 || math.IsInf(x, 0) || r.Cmp(max) >= 0 {
+		return Explanation{}, fmt.Errorf("%s is out of the range of %s", s, f.Name)
+	}
+	return Explain(f, m, x, maxDigits, precision), nil
+}
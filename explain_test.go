@@ -0,0 +1,169 @@
+package fptest
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	// The hardest cases are explained by themselves.
+	for _, f := range []Format{Float32, Float64} {
+		for _, m := range []Mode{Midpoints, HalfDecimals, NearFloats} {
+			digits := 9
+			if f == Float64 {
+				digits = 17
+			}
+			prec := f.MantBits + uint(2*digits)
+			BestFirst(f, m, digits, prec, 20, func(c HardCase) {
+				// The decimal of c has digits or digits-1 digits.
+				ex := Explain(f, m, c.X, digits, prec)
+				var e Explained
+				for _, ec := range ex.Cases {
+					if ec.HardCase == c {
+						e = ec
+					}
+				}
+				if e.Digits == 0 {
+					t.Fatalf("%s %s: Explain(%b) does not find %+v", f.Name, m, c.X, c)
+				}
+				if !e.Enumerated || e.Bits < float64(prec) {
+					t.Errorf("%s %s: %+v is not enumerated at precision %d (%.1f bits)",
+						f.Name, m, c, prec, e.Bits)
+				}
+				if frac, exp := math.Frexp(c.X); ex.Mant != frac || ex.Exp != exp {
+					t.Errorf("%s %s: wrong Frexp for %b", f.Name, m, c.X)
+				}
+				// The case follows the path of the target ratio.
+				path := e.Fraction.Path()
+				lower, upper := e.Lower.Path(), e.Upper.Path()
+				if common(path, lower) < len(path)/2 && common(path, upper) < len(path)/2 {
+					t.Errorf("%s %s: path %s does not follow %s or %s", f.Name, m, path, lower, upper)
+				}
+				if m != Midpoints {
+					return
+				}
+				s := strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)
+				exs, err := ExplainString(f, m, s, digits, prec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if exs.X != c.X {
+					t.Errorf("%s: ExplainString(%s).X = %b, want %b", f.Name, s, exs.X, c.X)
+				}
+			})
+		}
+	}
+}
+
+// common returns the length of the common prefix of s and t.
+func common(s, t string) int {
+	n := 0
+	for n < len(s) && n < len(t) && s[n] == t[n] {
+		n++
+	}
+	return n
+}
+
+func TestExplainEasy(t *testing.T) {
+	// 0.1 is 3602879701896397×2^-55, and 1 digit is enough.
+	ex := Explain(Float64, HalfDecimals, 0.1, 3, 60)
+	for _, c := range ex.Cases {
+		if c.Enumerated {
+			t.Errorf("0.1 is not hard: %+v", c)
+		}
+	}
+	ex, err := ExplainString(Float64, NearFloats, "0x1.999999999999ap-4", 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Cases) != 1 || ex.Cases[0].N != 1 || ex.Cases[0].K != -1 || ex.Cases[0].Bits < 54 {
+		t.Errorf("ExplainString(0.1) = %+v", ex)
+	}
+	for _, s := range []string{"abc", "-1", "0", "1e400"} {
+		if _, err := ExplainString(Float64, Midpoints, s, 17, 60); err == nil {
+			t.Errorf("ExplainString(%q) did not fail", s)
+		}
+	}
+}
+
+func TestExplainExamples(t *testing.T) {
+	// mktest explain 0x1.23p+456 9007199254740993e-3
+	for _, s := range []string{"0x1.23p+456", "9007199254740993e-3"} {
+		for _, digits := range []int{6, 17} {
+			ex, err := ExplainString(Float64, Midpoints, s, digits, 98)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ex.Cases) != digits {
+				t.Fatalf("%s: %d cases for %d digits", s, len(ex.Cases), digits)
+			}
+			for i, c := range ex.Cases {
+				n := len(strconv.FormatUint(c.N, 10))
+				if c.Digits != i+1 || n != c.Digits && c.N != pow10Big(c.Digits).Uint64() {
+					t.Errorf("%s: %de%d has not %d digits", s, c.N, c.K, i+1)
+				}
+				if math.Abs(c.Eps) > 0.5 {
+					t.Errorf("%s: %de%d is too far (eps=%g)", s, c.N, c.K, c.Eps)
+				}
+			}
+		}
+	}
+	// The float below 9007199254740993e-3 is 2^53×2^-9, and the midpoint
+	// is 9007199254740.993 within 2^-55.
+	ex, err := ExplainString(Float64, Midpoints, "9007199254740993e-3", 16, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ex.Cases[15]
+	if c.N != 9007199254740993 || c.K != -3 || c.Exp != -9 || !c.Enumerated || c.Bits < 55 {
+		t.Errorf("9007199254740993e-3: got %+v", c)
+	}
+	if c := ex.Cases[5]; c.N != 900720 || c.K != 7 || c.Enumerated {
+		t.Errorf("9007199254740993e-3 with 6 digits: got %+v", c)
+	}
+
+	// The nearest half-decimals of denormals are not 0.5×10^k.
+	for _, s := range []string{"5e-324", "1e-320"} {
+		ex, err := ExplainString(Float64, HalfDecimals, s, 6, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range ex.Cases {
+			if c.N == 0 || !c.Denormal || math.Abs(c.Eps) > 0.5 {
+				t.Errorf("%s: got %+v", s, c)
+			}
+		}
+	}
+}
+
+func TestRatPath(t *testing.T) {
+	for _, c := range []struct {
+		num, den uint64
+		path     string
+	}{
+		{1, 1, ""},
+		{2, 1, "R^1"},
+		{1, 2, "L^1"},
+		{7, 3, "R^2 L^2"},
+		{3, 7, "L^2 R^2"},
+		{355, 113, "R^3 L^7 R^15"},
+	} {
+		if p := exactRat(c.num, c.den, 64).Path(); p != c.path {
+			t.Errorf("%d/%d: path %q, want %q", c.num, c.den, p, c.path)
+		}
+	}
+	// The parent of the root 1/1 is 0/1, which is not in the tree.
+	root := exactRat(1, 1, 64)
+	if p := root.Path(); p != "" {
+		t.Errorf("1/1: path %q, want \"\"", p)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("path of 0/1 did not panic")
+			}
+		}()
+		root.Parent().Path()
+	}()
+}
@@ -0,0 +1,289 @@
+package fptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Query is a request of the line protocol used to check external
+// programs, with its expected reply. Requests are lines of the form
+//
+//	parse64 <decimal>
+//	format64 <hex bits> e <prec>
+//	format64 <hex bits> shortest -1
+//
+// (parse32 and format32 for float32). The program must answer each
+// request by a line: the bits of the parsed float in hexadecimal,
+// or the formatted float (as printf("%.*e") or the shortest
+// representation which parses back to the float).
+type Query struct {
+	Op   string // parse32, parse64, format32 or format64
+	Arg  string // the decimal, or the bits of the float
+	Mode string // e or shortest, for formatting
+	Prec int
+	Exp  int // binary exponent of the hard case
+	Want string
+}
+
+// String returns the request line of q.
+func (q Query) String() string {
+	if strings.HasPrefix(q.Op, "parse") {
+		return q.Op + " " + q.Arg
+	}
+	return q.Op + " " + q.Arg + " " + q.Mode + " " + strconv.Itoa(q.Prec)
+}
+
+// Kind returns the name of the request type of q,
+// such as parse64 or format64/e.
+func (q Query) Kind() string {
+	if q.Mode == "" {
+		return q.Op
+	}
+	return q.Op + "/" + q.Mode
+}
+
+// Check returns whether the reply of the program is correct.
+// Bits may have a 0x prefix and need not be zero-padded, and formatted numbers may use any
+// exponent syntax: for the e mode, the digits and the decimal
+// exponent must be those expected, and for the shortest mode,
+// the reply must have the expected value.
+func (q Query) Check(reply string) bool {
+	reply = strings.TrimSpace(reply)
+	switch {
+	case strings.HasPrefix(q.Op, "parse"):
+		b1, err1 := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(reply), "0x"), 16, 64)
+		b2, err2 := strconv.ParseUint(q.Want, 16, 64)
+		return err1 == nil && err2 == nil && b1 == b2
+	case q.Mode == "e":
+		d1, e1, ok1 := splitE(reply)
+		d2, e2, ok2 := splitE(q.Want)
+		return ok1 && ok2 && d1 == d2 && e1 == e2
+	}
+	r1, ok1 := new(big.Rat).SetString(reply)
+	r2, ok2 := new(big.Rat).SetString(q.Want)
+	return ok1 && ok2 && r1.Cmp(r2) == 0
+}
+
+// splitE splits a number d.ddde±x into its digits and exponent.
+func splitE(s string) (digits string, exp int, ok bool) {
+	i := strings.IndexAny(s, "eE")
+	if i < 0 {
+		return "", 0, false
+	}
+	exp, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+	return strings.Replace(s[:i], ".", "", 1), exp, err == nil
+}
+
+// Queries calls fn with the queries for the count hardest cases
+// of format f (Float32 or Float64, see BestFirst): the decimals close to midpoints must
+// be parsed, the floats around them must be formatted in shortest form,
+// and the floats close to half-decimals must be formatted with
+// digits significant digits.
+func Queries(f Format, digits int, precision uint, count int, fn func(q Query)) {
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		BestFirst(f, m, digits, precision, count, func(c HardCase) {
+			for _, q := range CaseQueries(f, m, c) {
+				fn(q)
+			}
+		})
+	}
+}
+
+// CaseQueries returns the queries for a hard case c of format f
+// (Float32 or Float64) and mode m (Midpoints or HalfDecimals), as
+// described for Queries. The floats close to half-decimals are
+// formatted with as many digits as c.N.
+func CaseQueries(f Format, m Mode, c HardCase) []Query {
+	if f != Float32 && f != Float64 {
+		panic("unsupported format " + f.Name)
+	}
+	bits := int(f.bits())
+	op := strconv.Itoa(bits)
+	hex := func(x float64) string {
+		if bits == 32 {
+			return fmt.Sprintf("%08x", math.Float32bits(float32(x)))
+		}
+		return fmt.Sprintf("%016x", math.Float64bits(x))
+	}
+	var qs []Query
+	switch m {
+	case Midpoints:
+		want := f.Rounded(c, Midpoints, big.ToNearestEven).Float
+		qs = append(qs, Query{Op: "parse" + op, Arg: strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K),
+			Exp: c.Exp, Want: hex(want)})
+		for _, x := range []float64{c.X, f.next(c.X)} {
+			if math.IsInf(x, 0) {
+				continue
+			}
+			qs = append(qs, Query{Op: "format" + op, Arg: hex(x), Mode: "shortest", Prec: -1,
+				Exp: c.Exp, Want: strconv.FormatFloat(x, 'e', -1, bits)})
+		}
+	case HalfDecimals:
+		digits := len(strconv.FormatUint(c.N, 10))
+		n := f.Rounded(c, HalfDecimals, big.ToNearestEven).Decimal
+		if want, ok := FormatFixedE(n, c.K, digits); ok {
+			qs = append(qs, Query{Op: "format" + op, Arg: hex(c.X), Mode: "e", Prec: digits - 1,
+				Exp: c.Exp, Want: want})
+		}
+	}
+	return qs
+}
+
+// An External is an external program answering queries
+// on its standard input and output.
+type External struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	w   *bufio.Writer
+	out *bufio.Scanner
+}
+
+// StartExternal starts the program name with the given arguments.
+func StartExternal(name string, args ...string) (*External, error) {
+	cmd := exec.Command(name, args...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &External{cmd: cmd, in: in, w: bufio.NewWriter(in), out: bufio.NewScanner(out)}, nil
+}
+
+// Ask sends the request of q and returns the reply.
+func (e *External) Ask(q Query) (string, error) {
+	if _, err := e.w.WriteString(q.String() + "\n"); err != nil {
+		return "", err
+	}
+	if err := e.w.Flush(); err != nil {
+		return "", err
+	}
+	if !e.out.Scan() {
+		if err := e.out.Err(); err != nil {
+			return "", err
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	return e.out.Text(), nil
+}
+
+// CheckCase asks the queries of the hard case c of format f and mode m
+// (see CaseQueries) and returns whether all replies are correct.
+// If fn is not nil, it is called with each query, its reply and
+// whether the reply is correct.
+func (e *External) CheckCase(f Format, m Mode, c HardCase, fn func(q Query, reply string, ok bool)) (bool, error) {
+	allOK := true
+	for _, q := range CaseQueries(f, m, c) {
+		reply, err := e.Ask(q)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", q, err)
+		}
+		ok := q.Check(reply)
+		if fn != nil {
+			fn(q, reply, ok)
+		}
+		allOK = allOK && ok
+	}
+	return allOK, nil
+}
+
+// Close closes the standard input of the program
+// and waits for its termination.
+func (e *External) Close() error {
+	e.in.Close()
+	return e.cmd.Wait()
+}
+
+// A Report counts queries and mismatches for each
+// kind of query and binary exponent.
+type Report struct {
+	counts map[reportKey]*[2]int
+}
+
+type reportKey struct {
+	kind string
+	exp  int
+}
+
+// Add records the result of a query.
+func (r *Report) Add(q Query, ok bool) {
+	if r.counts == nil {
+		r.counts = make(map[reportKey]*[2]int)
+	}
+	k := reportKey{q.Kind(), q.Exp}
+	c := r.counts[k]
+	if c == nil {
+		c = new([2]int)
+		r.counts[k] = c
+	}
+	c[0]++
+	if !ok {
+		c[1]++
+	}
+}
+
+// Mismatches returns the total number of mismatches.
+func (r *Report) Mismatches() int {
+	n := 0
+	for _, c := range r.counts {
+		n += c[1]
+	}
+	return n
+}
+
+// WriteTo writes the number of queries and mismatches of each kind,
+// and for each exponent with mismatches.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var keys []reportKey
+	totals := make(map[string]*[2]int)
+	var kinds []string
+	for k, c := range r.counts {
+		t := totals[k.kind]
+		if t == nil {
+			t = new([2]int)
+			totals[k.kind] = t
+			kinds = append(kinds, k.kind)
+		}
+		t[0] += c[0]
+		t[1] += c[1]
+		if c[1] > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(kinds)
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].exp < keys[j].exp
+	})
+	var n int64
+	for _, kind := range kinds {
+		m, err := fmt.Fprintf(w, "%s: %d queries, %d mismatches\n", kind, totals[kind][0], totals[kind][1])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	for _, k := range keys {
+		c := r.counts[k]
+		m, err := fmt.Fprintf(w, "%s e2=%d: %d/%d mismatches\n", k.kind, k.exp, c[1], c[0])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
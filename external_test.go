@@ -0,0 +1,139 @@
+package fptest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestHelperProcess is not a real test: it answers queries
+// using strconv when run by TestExternal. If FPTEST_HELPER is
+// "doublerounding", float32 are parsed through a float64.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("FPTEST_HELPER")
+	if mode == "" {
+		return
+	}
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		f := strings.Fields(in.Text())
+		bits := 64
+		if strings.HasSuffix(f[0], "32") {
+			bits = 32
+		}
+		switch {
+		case strings.HasPrefix(f[0], "parse"):
+			x, _ := strconv.ParseFloat(f[1], bits)
+			if bits == 32 {
+				if mode == "doublerounding" {
+					x64, _ := strconv.ParseFloat(f[1], 64)
+					x = float64(float32(x64))
+				}
+				fmt.Printf("%08x\n", math.Float32bits(float32(x)))
+			} else {
+				fmt.Printf("%016x\n", math.Float64bits(x))
+			}
+		default:
+			b, _ := strconv.ParseUint(f[1], 16, 64)
+			x := math.Float64frombits(b)
+			if bits == 32 {
+				x = float64(math.Float32frombits(uint32(b)))
+			}
+			prec, _ := strconv.Atoi(f[3])
+			fmt.Println(strconv.FormatFloat(x, 'e', prec, bits))
+		}
+	}
+	os.Exit(0)
+}
+
+func TestExternal(t *testing.T) {
+	for _, mode := range []string{"strconv", "doublerounding"} {
+		for _, f := range []Format{Float32, Float64} {
+			os.Setenv("FPTEST_HELPER", mode)
+			e, err := StartExternal(os.Args[0], "-test.run=TestHelperProcess")
+			os.Unsetenv("FPTEST_HELPER")
+			if err != nil {
+				t.Fatal(err)
+			}
+			digits := 9
+			if f == Float64 {
+				digits = 17
+			}
+			var r Report
+			prec := f.MantBits + uint(2*digits) + 8
+			Queries(f, digits, prec, 100, func(q Query) {
+				reply, err := e.Ask(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Add(q, q.Check(reply))
+			})
+			// The queries of the hardest case, one by one.
+			BestFirst(f, Midpoints, digits, prec, 1, func(c HardCase) {
+				n, failed := 0, 0
+				ok, err := e.CheckCase(f, Midpoints, c, func(q Query, reply string, ok bool) {
+					n++
+					if !ok {
+						failed++
+					}
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(CaseQueries(f, Midpoints, c)) || ok != (failed == 0) {
+					t.Errorf("%s %s: CheckCase returned %v after %d queries (%d failed)", mode, f.Name, ok, n, failed)
+				}
+			})
+			if err := e.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			r.WriteTo(&buf)
+			t.Logf("%s %s:\n%s", mode, f.Name, &buf)
+			bad := mode == "doublerounding" && f == Float32
+			if n := r.Mismatches(); n > 0 != bad {
+				t.Errorf("%s %s: %d mismatches", mode, f.Name, n)
+			}
+			if bad && !strings.Contains(buf.String(), "parse32 e2=") {
+				t.Errorf("%s %s: mismatches are not reported by exponent", mode, f.Name)
+			}
+		}
+	}
+}
+
+func TestQueryCheck(t *testing.T) {
+	for _, c := range []struct {
+		q     Query
+		reply string
+		ok    bool
+	}{
+		{Query{Op: "parse64", Want: "3ff0000000000000"}, "0x3FF0000000000000", true},
+		{Query{Op: "parse64", Want: "3ff0000000000000"}, "3ff0000000000001", false},
+		{Query{Op: "parse64", Want: "0000000000000001"}, "0x1", true},
+		{Query{Op: "parse32", Want: "00800000"}, "800000", true},
+		{Query{Op: "parse32", Want: "00800000"}, "8000000", false},
+		{Query{Op: "parse64", Want: "0000000000000001"}, "1p-1074", false},
+		{Query{Op: "format64", Mode: "e", Want: "1.250e+02"}, "1.250e2", true},
+		{Query{Op: "format64", Mode: "e", Want: "1.250e+02"}, "1.25e+02", false},
+		{Query{Op: "format64", Mode: "e", Want: "1.250e+02"}, "1.250E+002", true},
+		{Query{Op: "format64", Mode: "shortest", Want: "1e+16"}, "10000000000000000.0", true},
+		{Query{Op: "format64", Mode: "shortest", Want: "1e+16"}, "1.0000000000000002e16", false},
+	} {
+		if ok := c.q.Check(c.reply); ok != c.ok {
+			t.Errorf("%s: Check(%q) = %v, want %v", c.q.Kind(), c.reply, ok, c.ok)
+		}
+	}
+
+	// Queries are only defined for float32 and float64.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CaseQueries accepted %s", Float16.Name)
+		}
+	}()
+	CaseQueries(Float16, Midpoints, HardCase{N: 1, X: 1})
+}
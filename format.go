@@ -0,0 +1,70 @@
+package fptest
+
+// A Format describes a binary floating-point format.
+// Normal numbers are mant × 2^e where mant has MantBits bits
+// (including the implicit leading bit) and MinExp <= e <= MaxExp.
+// Denormal numbers have exponent MinExp and fewer mantissa bits.
+type Format struct {
+	Name     string
+	MantBits uint
+	MinExp   int
+	MaxExp   int
+}
+
+var (
+	Float64 = Format{Name: "float64", MantBits: 53, MinExp: -1074, MaxExp: 971}
+	Float32 = Format{Name: "float32", MantBits: 24, MinExp: -149, MaxExp: 104}
+	// Float16 is the IEEE 754 binary16 format.
+	Float16 = Format{Name: "float16", MantBits: 11, MinExp: -24, MaxExp: 5}
+	// BFloat16 is the bfloat16 format: a float32 with
+	// a mantissa truncated to 8 bits.
+	BFloat16 = Format{Name: "bfloat16", MantBits: 8, MinExp: -133, MaxExp: 120}
+)
+
+// A Mode selects a family of hard cases.
+type Mode int
+
+const (
+	// Midpoints are decimal numbers very close to a midpoint
+	// between consecutive floats (see AlmostDecimalMidpoint).
+	// They are hard cases for parsing and shortest formatting.
+	Midpoints Mode = iota
+	// HalfDecimals are floats very close to a half-decimal
+	// (n+1/2)×10^k (see AlmostHalfDecimal). They are hard cases
+	// for fixed precision formatting.
+	HalfDecimals
+	// NearFloats are decimal numbers very close to floats
+	// (see AlmostDecimal). They are hard cases for parsing
+	// and formatting in directed rounding modes.
+	NearFloats
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Midpoints:
+		return "midpoints"
+	case HalfDecimals:
+		return "halfdecimals"
+	case NearFloats:
+		return "nearfloats"
+	}
+	return "unknown"
+}
+
+// target returns the target of the enumeration of hard cases
+// for mode m and exponent e2, or nil if there are none.
+func (f Format) target(m Mode, e2 int, digits int, denormal bool) *target {
+	mantbits := f.MantBits
+	if denormal {
+		mantbits--
+	}
+	switch m {
+	case Midpoints:
+		return midpointTarget(e2, digits, mantbits, denormal)
+	case HalfDecimals:
+		return halfDecimalTarget(e2, digits, mantbits, denormal)
+	case NearFloats:
+		return decimalTarget(e2, digits, mantbits, denormal)
+	}
+	panic("invalid mode")
+}
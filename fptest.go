@@ -4,6 +4,8 @@ import (
 	"math"
 	"math/big"
 	"math/bits"
+	"strconv"
+	"strings"
 )
 
 // AlmostDecimalMidpoint enumerates floating-point numbers
@@ -14,22 +16,75 @@ import (
 // direction = 0  will return exact midpoints
 // direction = -1 will return numbers slightly below n × 10**k
 //
+// All such numbers are returned, including those where n and 2×mant+1
+// have a common factor: the Farey sequence only contains fractions
+// n/(2×mant+1) in lowest terms, and their multiples with a mantissa
+// of the right size are also emitted.
+//
 // Very close is interpreted as a relative difference less than
-// 1 / 2^precision.
+// 1 / 2^precision. Numbers are enumerated by increasing relative
+// difference, so that the hardest cases come first; they are not
+// sorted by value. For direction = +1, the fractions n/(2×mant+1)
+// are below the target ratio and WalkRange descends from it,
+// for direction = -1 they are above and it ascends.
 func AlmostDecimalMidpoint(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
 	f func(x float64, n uint64, k int)) {
-	if e2 > 0 {
-		almostDecimalPos(e2, digits, mantbits, precision, direction, f)
-	} else {
-		almostDecimalNeg(-e2, digits, mantbits, precision, direction, denormal, f)
+	// Midpoints below n/10**k are such that
+	// n / (2*mant+1) is above num/den
+	if t := midpointTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walk(precision, -direction, f)
 	}
 }
 
 const log2overlog10 = 0.30102999566398114
 
-// almostDecimalPos is AlmostDecimalMidpoint for e2 > 0.
-func almostDecimalPos(e2 int, digits int, mantbits, precision uint, direction int,
-	f func(x float64, n uint64, k int)) {
+// A target describes the fractions a/b to look for
+// near X = num/den, and how to map them to floating-point numbers.
+type target struct {
+	num, den *big.Int
+	nbits    uint // bit length of denominators
+	denormal bool
+	parity   Parity
+	// The float is (b/2)×2^exp2 (midpoints) or b×2^exp2,
+	// and the decimal exponent is exp10.
+	exp2, exp10 int
+	// emit calls f if a/b gives a valid case.
+	emit func(a, b uint64, f func(x float64, n uint64, k int))
+}
+
+// walk calls f for the cases given by fractions very close to X
+// (see WalkRange).
+func (t *target) walk(precision uint, direction int, f func(x float64, n uint64, k int)) {
+	WalkRange(t.num, t.den, precision, direction, t.nbits, func(r *Rat) bool {
+		t.emitAll(r, f)
+		return true
+	})
+}
+
+// emitAll calls f for the cases given by r and its multiples.
+func (t *target) emitAll(r *Rat, f func(x float64, n uint64, k int)) {
+	a, b := r.Fraction()
+	if t.parity == OddNumerator && a%2 == 0 || t.parity == OddDenominator && b%2 == 0 {
+		// All multiples are even.
+		return
+	}
+	multiples(a, b, t.nbits, t.denormal, func(a, b uint64) {
+		t.emit(a, b, f)
+	})
+}
+
+// midpointTarget returns the target of AlmostDecimalMidpoint,
+// or nil if there are no possible cases.
+func midpointTarget(e2 int, digits int, mantbits uint, denormal bool) *target {
+	if e2 > 0 {
+		return midpointTargetPos(e2, digits, mantbits)
+	} else {
+		return midpointTargetNeg(-e2, digits, mantbits, denormal)
+	}
+}
+
+// midpointTargetPos is midpointTarget for e2 > 0.
+func midpointTargetPos(e2 int, digits int, mantbits uint) *target {
 	// Find all rationals n / (2*mant+1) close to 2**(e2-1) / 10**k
 	//
 	// (k + digits) * log(10) == (mantbits + e2) * log(2)
@@ -40,29 +95,26 @@ func almostDecimalPos(e2 int, digits int, mantbits, precision uint, direction in
 	den := big.NewInt(10)
 	den.Exp(den, big.NewInt(int64(e10)), nil)
 
-	// Midpoints below n/10**k are such that
-	// n / (2*mant+1) is above num/den
-	r1, r2 := ratRange(num, den, precision, -direction, mantbits+1)
-
-	for r := r1; r.Less(r2); r.Next() {
-		a, b := r.Fraction()
-		//fmt.Println(r.cf, r.a, r.c)
-		if b%2 == 1 && bits.Len64(b) == int(mantbits+1) {
-			f(math.Ldexp(float64(b/2), e2), a, e10)
-		}
+	return &target{
+		num: num, den: den, nbits: mantbits + 1, parity: OddDenominator,
+		exp2: e2, exp10: e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			if b%2 == 1 {
+				f(math.Ldexp(float64(b/2), e2), a, e10)
+			}
+		},
 	}
 }
 
-// almostDecimalNeg enumerates numbers mant/2**e2 such that
+// midpointTargetNeg looks for numbers mant/2**e2 such that
 // the midpoint (mant+1/2)/2**e2 is very close to n/10**k for some integer n.
-func almostDecimalNeg(e2 int, digits int, mantbits, precision uint,
-	direction int, denormals bool, f func(x float64, n uint64, k int)) {
+func midpointTargetNeg(e2 int, digits int, mantbits uint, denormals bool) *target {
 	// Avoid the case where e10 < 0 below:
 	// we require that 2^mantbits/2^e2 < 10^digits
 	// otherwise it would mean we are looking for (mant+1/2)/2**e2
 	// very close to an integer, which is impossible.
 	if float64(int(mantbits)-e2)*log2overlog10 >= float64(digits) {
-		return
+		return nil
 	}
 
 	// Find all rationals n / (2*mant+1) close to 10**k/2**(e2+1)
@@ -78,15 +130,14 @@ func almostDecimalNeg(e2 int, digits int, mantbits, precision uint,
 	den := big.NewInt(1)
 	den.Lsh(den, uint(e2+1))
 
-	// Midpoints below n/10**k are such that
-	// n / (2*mant+1) is above num/den
-	r1, r2 := ratRange(num, den, precision, -direction, mantbits+1)
-
-	for r := r1; r.Less(r2); r.Next() {
-		a, b := r.Fraction()
-		if b%2 == 1 && (denormals || bits.Len64(b) == int(mantbits+1)) {
-			f(math.Ldexp(float64(b/2), -e2), a, -e10)
-		}
+	return &target{
+		num: num, den: den, nbits: mantbits + 1, denormal: denormals, parity: OddDenominator,
+		exp2: -e2, exp10: -e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			if b%2 == 1 {
+				f(math.Ldexp(float64(b/2), -e2), a, -e10)
+			}
+		},
 	}
 }
 
@@ -97,17 +148,31 @@ func almostDecimalNeg(e2 int, digits int, mantbits, precision uint,
 // Direction = +1 returns numbers slightly above
 // Direction = 0 returns numbers exactly equal to half a decimal
 //
+// As in AlmostDecimalMidpoint, numbers where 2n+1 and mant have
+// a common factor are included.
+//
+// As in AlmostDecimalMidpoint, numbers are enumerated by increasing
+// relative difference.
 func AlmostHalfDecimal(e2 int, digits int, mantbits, precision uint,
 	direction int, denormal bool, f func(x float64, n uint64, k int)) {
+	// Floats below a half-decimal are such that
+	// (2n+1)/mant is above num/den
+	if t := halfDecimalTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walk(precision, -direction, f)
+	}
+}
+
+// halfDecimalTarget returns the target of AlmostHalfDecimal,
+// or nil if there are no possible cases.
+func halfDecimalTarget(e2 int, digits int, mantbits uint, denormal bool) *target {
 	if e2 >= 0 {
-		almostHalfDecimalPos(e2, digits, mantbits, precision, direction, f)
+		return halfDecimalTargetPos(e2, digits, mantbits)
 	} else {
-		almostHalfDecimalNeg(-e2, digits, mantbits, precision, direction, denormal, f)
+		return halfDecimalTargetNeg(-e2, digits, mantbits, denormal)
 	}
 }
 
-func almostHalfDecimalPos(e2 int, digits int, mantbits, precision uint, direction int,
-	f func(float64, uint64, int)) {
+func halfDecimalTargetPos(e2 int, digits int, mantbits uint) *target {
 	// Find all rationals (2n+1) / mant close to 2**(e2+1) / 10**k
 	e10 := int(math.Ceil(float64(e2+int(mantbits))*log2overlog10)) - digits
 
@@ -116,41 +181,197 @@ func almostHalfDecimalPos(e2 int, digits int, mantbits, precision uint, directio
 	den := big.NewInt(10)
 	den.Exp(den, big.NewInt(int64(e10)), nil)
 
-	// Floats below a half-decimal are such that
-	// (2n+1)/mant is above num/den
-	r1, r2 := ratRange(num, den, precision, -direction, mantbits)
-
-	for r := r1; r.Less(r2); r.Next() {
-		a, b := r.Fraction()
-		if a%2 == 1 && bits.Len64(b) == int(mantbits) {
-			f(math.Ldexp(float64(b), e2), a/2, e10)
-		}
+	return &target{
+		num: num, den: den, nbits: mantbits, parity: OddNumerator,
+		exp2: e2, exp10: e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			if a%2 == 1 {
+				f(math.Ldexp(float64(b), e2), a/2, e10)
+			}
+		},
 	}
 }
 
-// almostHalfDecimalNeg implements AlmostHalfDecimal for negative exponents.
-func almostHalfDecimalNeg(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
-	f func(float64, uint64, int)) {
+// halfDecimalTargetNeg is halfDecimalTarget for negative exponents.
+func halfDecimalTargetNeg(e2 int, digits int, mantbits uint, denormal bool) *target {
 	// Find all rationals (2n+1) / mant close to 10**k / 2**(e2-1)
 	e10 := int(float64(e2-int(mantbits))*log2overlog10) + digits
+	if e10 < 0 {
+		// Half-decimals with so few digits are not
+		// in the range of the exponent.
+		return nil
+	}
 
 	num := big.NewInt(10)
 	num.Exp(num, big.NewInt(int64(e10)), nil)
 	den := big.NewInt(1)
 	den.Lsh(den, uint(e2-1))
 
-	// Floats below a half-decimal are such that
-	// (2n+1)/mant is above num/den
-	r1, r2 := ratRange(num, den, precision, -direction, mantbits)
+	return &target{
+		num: num, den: den, nbits: mantbits, denormal: denormal, parity: OddNumerator,
+		exp2: -e2, exp10: -e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			if a%2 == 1 {
+				f(math.Ldexp(float64(b), -e2), a/2, -e10)
+			}
+		},
+	}
+}
+
+// AlmostDecimal enumerates floating-point numbers mant×2**e2
+// very close to a decimal number n×10**k. They are the hard cases
+// of directed rounding modes, where rounding boundaries are the
+// floats themselves (for parsing) or the decimals (for formatting).
+//
+// Direction = -1 returns numbers slightly below
+// Direction = +1 returns numbers slightly above
+// Direction = 0 returns numbers exactly equal to a decimal
+//
+// As in AlmostDecimalMidpoint, numbers where n and mant have
+// a common factor are included.
+//
+// As in AlmostDecimalMidpoint, numbers are enumerated by increasing
+// relative difference.
+func AlmostDecimal(e2 int, digits int, mantbits, precision uint,
+	direction int, denormal bool, f func(x float64, n uint64, k int)) {
+	// Floats below a decimal are such that
+	// n/mant is above num/den
+	if t := decimalTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walk(precision, -direction, f)
+	}
+}
+
+// decimalTarget returns the target of AlmostDecimal,
+// or nil if there are no possible cases.
+func decimalTarget(e2 int, digits int, mantbits uint, denormal bool) *target {
+	if e2 >= 0 {
+		return decimalTargetPos(e2, digits, mantbits)
+	} else {
+		return decimalTargetNeg(-e2, digits, mantbits, denormal)
+	}
+}
+
+func decimalTargetPos(e2 int, digits int, mantbits uint) *target {
+	// Find all rationals n / mant close to 2**e2 / 10**k
+	e10 := int(math.Ceil(float64(e2+int(mantbits))*log2overlog10)) - digits
+
+	num := big.NewInt(1)
+	num.Lsh(num, uint(e2))
+	den := big.NewInt(10)
+	den.Exp(den, big.NewInt(int64(e10)), nil)
 
-	for r := r1; r.Less(r2); r.Next() {
-		a, b := r.Fraction()
-		if a%2 == 1 && (denormal || bits.Len64(b) == int(mantbits)) {
-			f(math.Ldexp(float64(b), -e2), a/2, -e10)
+	return &target{
+		num: num, den: den, nbits: mantbits, parity: AnyParity,
+		exp2: e2, exp10: e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			f(math.Ldexp(float64(b), e2), a, e10)
+		},
+	}
+}
+
+// decimalTargetNeg is decimalTarget for negative exponents.
+func decimalTargetNeg(e2 int, digits int, mantbits uint, denormal bool) *target {
+	// Find all rationals n / mant close to 10**k / 2**e2
+	e10 := int(float64(e2-int(mantbits))*log2overlog10) + digits
+	if e10 < 0 {
+		// Decimals with so few digits are not
+		// in the range of the exponent.
+		return nil
+	}
+
+	num := big.NewInt(10)
+	num.Exp(num, big.NewInt(int64(e10)), nil)
+	den := big.NewInt(1)
+	den.Lsh(den, uint(e2))
+
+	return &target{
+		num: num, den: den, nbits: mantbits, denormal: denormal, parity: AnyParity,
+		exp2: -e2, exp10: -e10,
+		emit: func(a, b uint64, f func(x float64, n uint64, k int)) {
+			f(math.Ldexp(float64(b), -e2), a, -e10)
+		},
+	}
+}
+
+// multiples calls f for the multiples (j×a, j×b) of an irreducible
+// fraction a/b such that j×b has exactly nbits bits (at most nbits
+// if denormal is set). The Farey sequence only contains irreducible
+// fractions, but mantissas sharing a common factor with the decimal
+// digits are also valid cases.
+func multiples(a, b uint64, nbits uint, denormal bool, f func(a, b uint64)) {
+	if a == 0 {
+		return
+	}
+	if !denormal && bits.Len64(b) == int(nbits) {
+		f(a, b)
+		return
+	}
+	max := ^uint64(0) >> (64 - nbits)
+	j := uint64(1)
+	if !denormal {
+		j = (max/2 + b) / b
+	}
+	for ; j <= max/b; j++ {
+		f(j*a, j*b)
+	}
+}
+
+// WalkRange calls f for each fraction with a denominator of at most
+// maxBits bits, very close to X=num/den (as in ratRange), starting from
+// the closest to X:
+// * direction=1 ascending from X, strictly above X
+// * direction=-1 descending from X, strictly below X
+// * direction=0 only X itself, if it is such a fraction
+//
+// The walk stops early if f returns false. The argument of f is
+// modified by the walk and must be cloned to be kept.
+func WalkRange(num, den *big.Int, precision uint, direction int, maxBits uint, f func(r *Rat) bool) {
+	for it := newRatIter(num, den, precision, direction, maxBits); !it.done; it.next() {
+		if !f(it.r) {
+			return
 		}
 	}
 }
 
+// A ratIter walks a Farey range from X outwards.
+type ratIter struct {
+	r    *Rat
+	end  *Rat // excluded upper bound, or included lower bound
+	desc bool
+	done bool
+}
+
+func newRatIter(num, den *big.Int, precision uint, direction int, maxBits uint) *ratIter {
+	if direction >= 0 {
+		r1, r2 := ratRange(num, den, precision, direction, maxBits)
+		return &ratIter{r: r1, end: r2, done: !r1.Less(r2)}
+	}
+	r, up := NewRatFromBig(num, den, maxBits)
+	it := &ratIter{r: r, desc: true}
+	if r.Equals(up) {
+		// X itself is excluded.
+		if r.a == 0 {
+			it.done = true
+			return it
+		}
+		r.Prev()
+	}
+	it.end = slightlyOff(num, den, precision, -1, maxBits)
+	it.done = r.Less(it.end)
+	return it
+}
+
+func (it *ratIter) next() {
+	switch {
+	case !it.desc:
+		it.done = !it.r.Next().Less(it.end)
+	case it.r.a == 0:
+		it.done = true
+	default:
+		it.done = it.r.Prev().Less(it.end)
+	}
+}
+
 // ratRange returns an half-open interval [r1, r2) which enumerates
 // rationals with a given bit length, very close to X=num/den
 // * direction=1 strictly above X up to a 2^-precision relative difference
@@ -351,6 +572,74 @@ euclid:
 	return
 }
 
+// NewRatFromCF returns the Rat with continued fraction expansion
+// [cf[0]; cf[1], ..., cf[n]]. Only cf[0] may be zero.
+// It panics if the numerator does not fit in 64 bits or the
+// denominator does not fit in maxBits bits.
+func NewRatFromCF(cf []uint64, maxBits uint) *Rat {
+	if len(cf) == 0 {
+		panic("empty continued fraction")
+	}
+	r := &Rat{maxBits: maxBits, a: 1, d: 1}
+	for i, q := range cf {
+		if i > 0 && q == 0 {
+			panic("zero coefficient in continued fraction")
+		}
+		ah, al := bits.Mul64(q, r.a)
+		al, carry := bits.Add64(al, r.b, 0)
+		ch, cl := bits.Mul64(q, r.c)
+		cl, carry2 := bits.Add64(cl, r.d, 0)
+		if ah+carry != 0 || ch+carry2 != 0 {
+			panic("continued fraction overflows 64 bits")
+		}
+		r.cf = append(r.cf, q)
+		r.a, r.b = al, r.a
+		r.c, r.d = cl, r.c
+	}
+	if bits.Len64(r.c) > int(maxBits) {
+		panic("denominator is larger than maxBits")
+	}
+	r.normalize()
+	return r
+}
+
+// NewRatFromBigRat is like NewRatFromBig for a positive big.Rat.
+func NewRatFromBigRat(x *big.Rat, maxBits uint) (lower, upper *Rat) {
+	if x.Sign() < 0 {
+		panic("negative rational")
+	}
+	return NewRatFromBig(x.Num(), x.Denom(), maxBits)
+}
+
+// exactRat returns num/den as a Rat, using the Euclidean algorithm.
+func exactRat(num, den uint64, maxBits uint) *Rat {
+	var cf []uint64
+	for den != 0 {
+		cf = append(cf, num/den)
+		num, den = den, num%den
+	}
+	return NewRatFromCF(cf, maxBits)
+}
+
+// Mediant returns the mediant (a+c)/(b+d) of fractions a/b and c/d,
+// reduced to lowest terms. Its maximal denominator size is the largest
+// of r and s. It panics if the sums overflow.
+//
+// The mediant of consecutive terms of a Farey sequence is their
+// common parent in the Stern-Brocot tree.
+func Mediant(r, s *Rat) *Rat {
+	num, c1 := bits.Add64(r.a, s.a, 0)
+	den, c2 := bits.Add64(r.c, s.c, 0)
+	if c1 != 0 || c2 != 0 {
+		panic("mediant overflows 64 bits")
+	}
+	maxBits := r.maxBits
+	if s.maxBits > maxBits {
+		maxBits = s.maxBits
+	}
+	return exactRat(num, den, maxBits)
+}
+
 func (r *Rat) appendContinued(q uint64) {
 	r.cf = append(r.cf, q)
 	r.a, r.b = q*r.a+r.b, r.a
@@ -359,7 +648,16 @@ func (r *Rat) appendContinued(q uint64) {
 
 // Normalize a continued fraction:
 // replace [a1 ... an, 1] by [a1 ... (an+1)]
+// and [a1 ... an, b, 0] by [a1 ... an]
 func (r *Rat) normalize() {
+	if n := len(r.cf); n > 2 && r.cf[n-1] == 0 {
+		// Divide by (b 1) (0 1) = (1 b)
+		//           (1 0) (1 0)   (0 1)
+		q := r.cf[n-2]
+		r.cf = r.cf[:n-2]
+		r.b -= q * r.a
+		r.d -= q * r.c
+	}
 	if len(r.cf) == 1 {
 		return
 	}
@@ -425,6 +723,84 @@ func (r *Rat) Less(s *Rat) bool {
 	return x1 < y1 || (x1 == y1 && x0 < y0)
 }
 
+// Cmp compares r and s and returns -1, 0 or +1.
+func (r *Rat) Cmp(s *Rat) int {
+	x1, x0 := bits.Mul64(r.a, s.c)
+	y1, y0 := bits.Mul64(s.a, r.c)
+	switch {
+	case x1 < y1 || (x1 == y1 && x0 < y0):
+		return -1
+	case x1 == y1 && x0 == y0:
+		return 0
+	}
+	return +1
+}
+
+// BigRat returns the value of r as a big.Rat.
+func (r *Rat) BigRat() *big.Rat {
+	return new(big.Rat).SetFrac(
+		new(big.Int).SetUint64(r.a),
+		new(big.Int).SetUint64(r.c))
+}
+
+// MaxBits returns the maximal bit length of denominators
+// used by Next and Prev.
+func (r *Rat) MaxBits() uint { return r.maxBits }
+
+// ContinuedFraction returns the (normalized) continued fraction
+// expansion of r: the last coefficient is at least 2 unless r is an integer.
+func (r *Rat) ContinuedFraction() []uint64 {
+	return append([]uint64(nil), r.cf...)
+}
+
+// Depth returns the depth of r in the Stern-Brocot tree,
+// the root 1/1 having depth 0. It panics if r is 0/1,
+// which is not part of the tree.
+func (r *Rat) Depth() uint64 {
+	var depth uint64
+	for _, q := range r.cf {
+		depth += q
+	}
+	if depth == 0 {
+		panic("0/1 has no depth")
+	}
+	return depth - 1
+}
+
+// Parent mutates r to its parent in the Stern-Brocot tree.
+// The parent of the root 1/1 is 0/1, which has no parent.
+func (r *Rat) Parent() *Rat {
+	last := r.cf[len(r.cf)-1]
+	if last == 0 {
+		panic("0/1 has no parent")
+	}
+	r.cf[len(r.cf)-1] = last - 1
+	r.a -= r.b
+	r.c -= r.d
+	r.normalize()
+	return r
+}
+
+// LeftChild mutates r to its left child in the Stern-Brocot tree,
+// regardless of the bound on denominators.
+func (r *Rat) LeftChild() *Rat {
+	if r.a == 0 {
+		panic("0/1 has no children")
+	}
+	r.child(0)
+	return r
+}
+
+// RightChild mutates r to its right child in the Stern-Brocot tree,
+// regardless of the bound on denominators.
+func (r *Rat) RightChild() *Rat {
+	if r.a == 0 {
+		panic("0/1 has no children")
+	}
+	r.child(1)
+	return r
+}
+
 // child mutates r to its left(idx=0) orright(idx=1)
 // child in the Stern-Brocot tree.
 func (r *Rat) child(idx int) {
@@ -536,3 +912,79 @@ func (r *Rat) Next() *Rat {
 	}
 	return r
 }
+
+// Prev mutates r to the previous rational number in the Farey sequence
+// F_(1<<maxBits-1). It is the mirror image of Next.
+func (r *Rat) Prev() *Rat {
+	// The previous element in the tree is either:
+	// - the right-most leaf from the left child
+	// - the first left-ancestor, i.e. N such that
+	//   r is the left-most leaf of N.right_child
+	if r.a == 0 {
+		panic("0/1 has no predecessor")
+	}
+	_, den := r.peekChild(0)
+	if bits.Len64(den) <= int(r.maxBits) && den >= r.c {
+		// Left child is within bounds, go right-most.
+		r.child(0)
+
+		for {
+			_, den = r.peekChild(1)
+			if bits.Len64(den) > int(r.maxBits) || den < r.c {
+				break
+			}
+			if len(r.cf)%2 == 1 {
+				// Going right-most is just increasing the last coefficient
+				// while keeping r.c length <= maxBits.
+				// Try skipping many children.
+				var maxc uint64 = 1<<(r.maxBits-1) + (1<<(r.maxBits-1) - 1)
+				maxquo := (maxc - r.c) / r.d
+				if maxquo > 0 {
+					r.cf[len(r.cf)-1] += maxquo
+					r.a += maxquo * r.b
+					r.c += maxquo * r.d
+					continue
+				}
+			}
+			r.child(1)
+		}
+	} else if len(r.cf)%2 == 1 {
+		// Left child is out of bounds and r is a right child:
+		// the previous element is the parent.
+		r.Parent()
+	} else {
+		// r is the left-most leaf of (..k+1), the right child of (..k).
+		// Go up to (..k).
+		n := r.cf[len(r.cf)-1]
+		r.cf = r.cf[:len(r.cf)-1]
+		r.a, r.b = r.b, r.a-n*r.b
+		r.c, r.d = r.d, r.c-n*r.d
+		r.normalize()
+	}
+	return r
+}
+
+// Path returns the path from the root 1/1 to r in the Stern-Brocot
+// tree, as runs of right (R) and left (L) moves: 7/3 = [2; 3] is
+// reached by "R^2 L^2". The path of the root is empty. It panics
+// if r is 0/1, which is not part of the tree.
+func (r *Rat) Path() string {
+	if r.a == 0 {
+		panic("0/1 has no path")
+	}
+	var runs []string
+	for i, q := range r.cf {
+		if i == len(r.cf)-1 {
+			q--
+		}
+		if q == 0 {
+			continue
+		}
+		move := "R"
+		if i%2 == 1 {
+			move = "L"
+		}
+		runs = append(runs, move+"^"+strconv.FormatUint(q, 10))
+	}
+	return strings.Join(runs, " ")
+}
@@ -2,6 +2,7 @@ package fptest
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"math/bits"
 	"testing"
@@ -204,3 +205,287 @@ func BenchmarkRat_Interval(b *testing.B) {
 		}
 	}
 }
+
+func TestEnumerateMultiples(t *testing.T) {
+	// Compare the enumerators with an exhaustive search for an
+	// 11-bit mantissa. Mantissas sharing a common factor with the
+	// decimal digits are not in the Farey sequence but are cases.
+	const mantbits = 11
+	type hardCase struct {
+		x float64
+		n uint64
+		k int
+	}
+	decimal := func(n uint64, k int) *big.Rat {
+		d := new(big.Rat).SetInt64(int64(n))
+		p := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(k))), nil))
+		if k < 0 {
+			return d.Quo(d, p)
+		}
+		return d.Mul(d, p)
+	}
+	// eps returns the relative difference of the (half) decimal
+	// n×10^k and the float x (or the midpoint above x).
+	eps := func(half bool, e2 int, x float64, n uint64, k int) *big.Rat {
+		bin := new(big.Rat).SetFloat64(x)
+		dec := decimal(n, k)
+		if half {
+			dec.Add(dec, decimal(5, k-1))
+		} else {
+			bin.Add(bin, new(big.Rat).SetFloat64(math.Ldexp(0.5, e2)))
+		}
+		e := dec.Quo(dec, bin)
+		return e.Sub(e, big.NewRat(1, 1))
+	}
+	shared := 0
+	for _, half := range []bool{false, true} {
+		enum := AlmostDecimalMidpoint
+		if half {
+			enum = AlmostHalfDecimal
+		}
+		for e2 := -60; e2 <= 60; e2++ {
+			for digits := 1; digits <= 5; digits++ {
+				var e10 int
+				if e2 > 0 || half && e2 == 0 {
+					e10 = int(math.Ceil(float64(e2+mantbits)*log2overlog10)) - digits
+				} else {
+					e10 = -int(float64(-e2-mantbits)*log2overlog10) - digits
+				}
+				prec := uint(mantbits + 2*digits)
+				max := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), prec))
+				for _, dir := range []int{-1, +1} {
+					got := make(map[hardCase]bool)
+					// Below X, the enumerators also return the
+					// fraction at the end of their range, which
+					// may be far from X: all cases beyond the
+					// precision must have the same ratio.
+					var far *big.Rat
+					enum(e2, digits, mantbits, prec, dir, false, func(x float64, n uint64, k int) {
+						got[hardCase{x, n, k}] = true
+						e := eps(half, e2, x, n, k)
+						if e.Sign() != -dir {
+							t.Errorf("half=%v e2=%d digits=%d dir=%d: %ve%d for %v (eps=%s)",
+								half, e2, digits, dir, n, k, x, e.FloatString(6))
+							return
+						}
+						if new(big.Rat).Abs(e).Cmp(max) < 0 {
+							return
+						}
+						if dir < 0 || far != nil && far.Cmp(e) != 0 {
+							t.Errorf("half=%v e2=%d digits=%d dir=%d: %ve%d for %v (eps=%s)",
+								half, e2, digits, dir, n, k, x, e.FloatString(6))
+						}
+						far = e
+					})
+					if e2 < 0 && e10 > 0 {
+						// Decimals with so few digits are out of
+						// the range of negative exponents.
+						continue
+					}
+					for mant := uint64(1) << (mantbits - 1); mant < 1<<mantbits; mant++ {
+						x := math.Ldexp(float64(mant), e2)
+						// The nearest (half) decimal.
+						bin := new(big.Rat).SetFloat64(x)
+						if half {
+							bin.Sub(bin, decimal(5, e10-1))
+						} else {
+							bin.Add(bin, new(big.Rat).SetFloat64(math.Ldexp(0.5, e2)))
+						}
+						q := bin.Quo(bin, decimal(1, e10))
+						q.Add(q, big.NewRat(1, 2))
+						n := new(big.Int).Quo(q.Num(), q.Denom())
+						if n.Sign() <= 0 {
+							continue
+						}
+						e := eps(half, e2, x, n.Uint64(), e10)
+						if e.Sign() != -dir || new(big.Rat).Abs(e).Cmp(max) >= 0 {
+							continue
+						}
+						c := hardCase{x, n.Uint64(), e10}
+						if !got[c] {
+							t.Errorf("half=%v e2=%d digits=%d dir=%d: missing %ve%d for %v",
+								half, e2, digits, dir, c.n, c.k, c.x)
+						}
+						a, b := n.Uint64(), mant
+						if half {
+							a = 2*a + 1
+						} else {
+							b = 2*b + 1
+						}
+						g := new(big.Int).GCD(nil, nil, new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+						if g.Cmp(big.NewInt(1)) > 0 {
+							shared++
+						}
+					}
+				}
+			}
+		}
+	}
+	if shared == 0 {
+		t.Errorf("no cases with a common factor")
+	}
+	t.Logf("%d cases with a common factor", shared)
+}
+
+func TestRatPrev(t *testing.T) {
+	// Walk the Farey sequence F_63 in both directions.
+	var seq []*Rat
+	r, _ := NewRat(0, 1, 6)
+	for r.a != 1 || r.c != 1 {
+		seq = append(seq, r.clone())
+		r.Next()
+	}
+	for i := len(seq) - 1; i >= 0; i-- {
+		r.Prev()
+		if !r.Equals(seq[i]) {
+			t.Fatalf("expected %d/%d, got %d/%d", seq[i].a, seq[i].c, r.a, r.c)
+		}
+		if num, den := r.slowFrac(); num != r.a || den != r.c {
+			t.Errorf("expected %d/%d, got %d/%d", num, den, r.a, r.c)
+		}
+	}
+
+	// Large denominators: Prev is the inverse of Next.
+	n, _ := new(big.Int).SetString("717897987691852588770249", 10)
+	d, _ := new(big.Int).SetString("1000000000000000000000000", 10)
+	for _, bits := range []uint{8, 24, 53, 64} {
+		lo, up := NewRatFromBig(n, d, bits)
+		r := up.clone()
+		for i := 0; i < 1000; i++ {
+			r.Next()
+		}
+		for i := 0; i < 1000; i++ {
+			r.Prev()
+		}
+		if !r.Equals(up) {
+			t.Errorf("bits=%d: expected %d/%d, got %d/%d", bits, up.a, up.c, r.a, r.c)
+		}
+		if !up.Equals(lo) && !r.Prev().Equals(lo) {
+			t.Errorf("bits=%d: expected %d/%d, got %d/%d", bits, lo.a, lo.c, r.a, r.c)
+		}
+	}
+}
+
+func TestRatPrevNormalize(t *testing.T) {
+	// The lower approximation of 10^288 / 2^999 used to have
+	// a continued fraction ending with 0.
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(288), nil)
+	d := new(big.Int).Lsh(big.NewInt(1), 999)
+	lo, up := NewRatFromBig(n, d, 53)
+	if cf := lo.ContinuedFraction(); cf[len(cf)-1] < 2 {
+		t.Errorf("not normalized: %v", cf)
+	}
+	r := lo.clone()
+	for i := 0; i < 100; i++ {
+		r.Prev()
+		if num, den := r.slowFrac(); num != r.a || den != r.c {
+			t.Fatalf("expected %d/%d, got %d/%d", num, den, r.a, r.c)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		r.Next()
+	}
+	if !r.Equals(lo) || !r.Next().Equals(up) {
+		t.Errorf("Prev and Next are not inverses")
+	}
+}
+
+func TestRatTree(t *testing.T) {
+	r := NewRatFromCF([]uint64{0, 1, 2, 1}, 8)
+	if num, den := r.Fraction(); num != 3 || den != 4 {
+		t.Errorf("[0;1,2,1] = %d/%d, expected 3/4", num, den)
+	}
+	if cf := r.ContinuedFraction(); fmt.Sprint(cf) != "[0 1 3]" {
+		t.Errorf("got %v, expected [0 1 3]", cf)
+	}
+	if d := r.Depth(); d != 3 {
+		t.Errorf("depth of 3/4 is %d, expected 3", d)
+	}
+	// The path from 3/4 to the root.
+	var path []string
+	for s := r.clone(); s.a != 0; s.Parent() {
+		path = append(path, fmt.Sprintf("%d/%d", s.a, s.c))
+	}
+	if got := fmt.Sprint(path); got != "[3/4 2/3 1/2 1/1]" {
+		t.Errorf("got path %s", got)
+	}
+	// Children are mediants with the nearest ancestors.
+	left := r.clone().LeftChild()
+	right := r.clone().RightChild()
+	if left.a != 5 || left.c != 7 || right.a != 4 || right.c != 5 {
+		t.Errorf("children of 3/4: %d/%d %d/%d", left.a, left.c, right.a, right.c)
+	}
+	if !left.clone().Parent().Equals(r) || !right.clone().Parent().Equals(r) {
+		t.Errorf("parent of children of 3/4 is not 3/4")
+	}
+	half, one := NewRatFromCF([]uint64{0, 2}, 8), NewRatFromCF([]uint64{1}, 8)
+	if m := Mediant(half, one); !m.Equals(NewRatFromCF([]uint64{0, 1, 2}, 8)) {
+		t.Errorf("mediant of 1/2 and 1/1 is %d/%d", m.a, m.c)
+	}
+	if m := Mediant(r, r); m.Cmp(r) != 0 {
+		t.Errorf("mediant of 3/4 and 3/4 is %d/%d", m.a, m.c)
+	}
+	if d := one.Depth(); d != 0 {
+		t.Errorf("depth of 1/1 is %d, expected 0", d)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("depth of 0/1 did not panic")
+			}
+		}()
+		one.clone().Parent().Depth()
+	}()
+	if r.Cmp(left) != 1 || r.Cmp(right) != -1 || r.Cmp(r.clone()) != 0 {
+		t.Errorf("incorrect comparisons")
+	}
+	// Conversion to and from big.Rat.
+	x := big.NewRat(355, 113)
+	lo, up := NewRatFromBigRat(x, 8)
+	if lo.BigRat().Cmp(x) != 0 || up.BigRat().Cmp(x) != 0 {
+		t.Errorf("got %v %v, expected 355/113", lo.BigRat(), up.BigRat())
+	}
+}
+
+func TestWalkRange(t *testing.T) {
+	// 2^200 / 10^60 at various precisions.
+	num := new(big.Int).Lsh(big.NewInt(1), 200)
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(60), nil)
+	for _, prec := range []uint{96, 100, 104, 108} {
+		r1, r2 := ratRange(num, den, prec, -1, 54)
+		var asc []string
+		for r := r1; r.Less(r2); r.Next() {
+			asc = append(asc, fmt.Sprintf("%d/%d", r.a, r.c))
+		}
+		var desc []string
+		var last *Rat
+		WalkRange(num, den, prec, -1, 54, func(r *Rat) bool {
+			if last != nil && !r.Less(last) {
+				t.Errorf("not descending: %d/%d then %d/%d", last.a, last.c, r.a, r.c)
+			}
+			last = r.clone()
+			desc = append(desc, fmt.Sprintf("%d/%d", r.a, r.c))
+			return true
+		})
+		if len(asc) != len(desc) {
+			t.Errorf("prec=%d: %d fractions ascending, %d descending", prec, len(asc), len(desc))
+			continue
+		}
+		for i := range asc {
+			if asc[i] != desc[len(desc)-1-i] {
+				t.Errorf("prec=%d: mismatch %s != %s", prec, asc[i], desc[len(desc)-1-i])
+			}
+		}
+		t.Logf("prec=%d: %d fractions", prec, len(desc))
+	}
+
+	// Small denominators below 1/3 and exact X.
+	var got []string
+	WalkRange(big.NewInt(1), big.NewInt(3), 2, -1, 3, func(r *Rat) bool {
+		got = append(got, fmt.Sprintf("%d/%d", r.a, r.c))
+		return true
+	})
+	if s := fmt.Sprint(got); s != "[2/7 1/4]" {
+		t.Errorf("got %s, expected [2/7 1/4]", s)
+	}
+}
@@ -0,0 +1,206 @@
+package fptest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// A Seeder receives seed inputs for a fuzz test.
+// It is implemented by *testing.F and CorpusWriter.
+type Seeder interface {
+	Add(args ...interface{})
+}
+
+// A SeedType selects how hard cases are passed to fuzz targets.
+type SeedType int
+
+const (
+	// DecimalSeed is a string "NeK" for parsers: for Midpoints
+	// and NearFloats, the decimal number N×10^K; for HalfDecimals,
+	// the shortest representation of X.
+	DecimalSeed SeedType = iota
+	// FloatSeed is a float64 or float32 value for formatters.
+	// For Midpoints both floats around the midpoint are added.
+	FloatSeed
+	// BitsSeed is like FloatSeed, using a uint64 or uint32 bit pattern.
+	BitsSeed
+)
+
+// AddSeeds adds the count hardest cases of format f and mode m
+// (as enumerated by BestFirst) to the seed corpus of s.
+func AddSeeds(s Seeder, f Format, m Mode, typ SeedType, digits int, precision uint, count int) {
+	BestFirst(f, m, digits, precision, count, func(c HardCase) {
+		for _, args := range seedArgs(f, m, typ, c) {
+			s.Add(args)
+		}
+	})
+}
+
+// seedArgs returns the seed values for a hard case.
+func seedArgs(f Format, m Mode, typ SeedType, c HardCase) []interface{} {
+	if typ == DecimalSeed {
+		if m != HalfDecimals {
+			return []interface{}{strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)}
+		}
+		return []interface{}{strconv.FormatFloat(c.X, 'e', -1, int(f.bits()))}
+	}
+	xs := []float64{c.X}
+	if m == Midpoints {
+		xs = append(xs, f.next(c.X))
+	}
+	var args []interface{}
+	for _, x := range xs {
+		switch {
+		case f.bits() == 32 && typ == FloatSeed:
+			args = append(args, float32(x))
+		case f.bits() == 32:
+			args = append(args, math.Float32bits(float32(x)))
+		case typ == FloatSeed:
+			args = append(args, x)
+		default:
+			args = append(args, math.Float64bits(x))
+		}
+	}
+	return args
+}
+
+// bits returns the storage size of numbers of format f.
+func (f Format) bits() uint {
+	if f.MantBits <= 24 {
+		return 32
+	}
+	return 64
+}
+
+// next returns the float following x in format f.
+func (f Format) next(x float64) float64 {
+	if f.bits() == 32 {
+		return float64(math.Nextafter32(float32(x), float32(math.Inf(1))))
+	}
+	return math.Nextafter(x, math.Inf(1))
+}
+
+// A CorpusWriter is a Seeder which writes seeds as files
+// of the Go fuzzing corpus, usually testdata/fuzz/<FuzzName>.
+// The first error is kept in Err.
+type CorpusWriter struct {
+	Dir string
+	Err error
+}
+
+// NewCorpusWriter creates the corpus directory
+// testdata/fuzz/<name> under root.
+func NewCorpusWriter(root, name string) (*CorpusWriter, error) {
+	dir := filepath.Join(root, "testdata", "fuzz", name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &CorpusWriter{Dir: dir}, nil
+}
+
+// Add writes a corpus file for the given values, named after their hash.
+func (w *CorpusWriter) Add(args ...interface{}) {
+	if w.Err != nil {
+		return
+	}
+	data := []byte("go test fuzz v1\n")
+	for _, arg := range args {
+		data = append(data, corpusValue(arg)...)
+		data = append(data, '\n')
+	}
+	name := fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+	w.Err = os.WriteFile(filepath.Join(w.Dir, name), data, 0644)
+}
+
+// corpusValue encodes a value like the Go fuzzing engine.
+func corpusValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return "string(" + strconv.Quote(v) + ")"
+	case float64:
+		if math.IsNaN(v) || math.Signbit(v) && v == 0 {
+			return fmt.Sprintf("math.Float64frombits(%#x)", math.Float64bits(v))
+		}
+		return "float64(" + strconv.FormatFloat(v, 'g', -1, 64) + ")"
+	case float32:
+		if math.IsNaN(float64(v)) || math.Signbit(float64(v)) && v == 0 {
+			return fmt.Sprintf("math.Float32frombits(%#x)", math.Float32bits(v))
+		}
+		return "float32(" + strconv.FormatFloat(float64(v), 'g', -1, 32) + ")"
+	case uint64, uint32, int, int64, uint8:
+		return fmt.Sprintf("%T(%d)", v, v)
+	}
+	panic(fmt.Sprintf("unsupported corpus value type %T", v))
+}
+
+// HardCaseAt maps arbitrary integers, usually given by a fuzzer,
+// onto a hard case of format f and mode m with a relative difference
+// less than 2^-precision, so that fuzzing explores hard cases directly.
+//
+// The exponent is reduced modulo the exponent range of the format
+// (including denormals), the number of digits modulo maxDigits, and the
+// index modulo the number of cases (on both sides, see CountRange).
+// It returns false if there are no cases for that exponent and
+// number of digits.
+func HardCaseAt(f Format, m Mode, exp, digits int, index uint64, maxDigits int, precision uint) (HardCase, bool) {
+	nexp := f.MaxExp - f.MinExp + 2
+	e2 := f.MinExp - 1 + mod(exp, nexp)
+	denormal := e2 < f.MinExp
+	if denormal {
+		e2 = f.MinExp
+	}
+	digits = 1 + mod(digits, maxDigits)
+	t := f.target(m, e2, digits, denormal)
+	if t == nil {
+		return HardCase{}, false
+	}
+	var counters [2]*counter
+	// Below X, the range starts with a fraction beyond the precision
+	// (as in countWithin): its multiples come first and are skipped.
+	var skip uint64
+	for i, dir := range []int{-1, +1} {
+		r1, r2 := ratRange(t.num, t.den, precision, dir, t.nbits)
+		counters[i] = newCounter(r1, r2, t.nbits, t.denormal, t.parity)
+		if dir == -1 && r1.Less(r2) {
+			skip = newCounter(r1, r1.clone().Next(), t.nbits, t.denormal, t.parity).total
+		}
+	}
+	below := counters[0].total - skip
+	total := below + counters[1].total
+	if total == 0 || total < below {
+		// Empty, or overflow.
+		return HardCase{}, false
+	}
+	index %= total
+	c := counters[0]
+	if index < below {
+		index += skip
+	} else {
+		index -= below
+		c = counters[1]
+	}
+	a, b := c.ith(index)
+	eps := new(big.Rat).SetFrac(
+		new(big.Int).Mul(new(big.Int).SetUint64(a), t.den),
+		new(big.Int).Mul(new(big.Int).SetUint64(b), t.num))
+	eps.Sub(eps, big.NewRat(1, 1))
+	hc := HardCase{Exp: e2}
+	hc.Eps, _ = eps.Float64()
+	t.emit(a, b, func(x float64, n uint64, k int) {
+		hc.X, hc.N, hc.K = x, n, k
+	})
+	return hc, true
+}
+
+func mod(a, n int) int {
+	a %= n
+	if a < 0 {
+		a += n
+	}
+	return a
+}
@@ -0,0 +1,151 @@
+package fptest
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type seedList [][]interface{}
+
+func (s *seedList) Add(args ...interface{}) { *s = append(*s, args) }
+
+func TestAddSeeds(t *testing.T) {
+	var seeds seedList
+	AddSeeds(&seeds, Float64, Midpoints, DecimalSeed, 17, 100, 50)
+	if len(seeds) != 50 {
+		t.Fatalf("got %d seeds", len(seeds))
+	}
+	for _, args := range seeds {
+		s := args[0].(string)
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			t.Errorf("invalid seed %q: %s", s, err)
+		}
+	}
+
+	seeds = nil
+	AddSeeds(&seeds, Float32, Midpoints, BitsSeed, 9, 50, 20)
+	for _, args := range seeds {
+		if _, ok := args[0].(uint32); !ok {
+			t.Errorf("invalid seed %#v", args)
+		}
+	}
+	if len(seeds) != 40 {
+		t.Errorf("got %d seeds, expected 2×20", len(seeds))
+	}
+}
+
+func TestCorpusWriter(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewCorpusWriter(dir, "FuzzTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Add("1e23", float64(1e23), float32(0.1), uint64(42))
+	w.Add(math.Copysign(0, -1))
+	if w.Err != nil {
+		t.Fatal(w.Err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "testdata", "fuzz", "FuzzTest", "*"))
+	if len(files) != 2 {
+		t.Fatalf("got %d files", len(files))
+	}
+	var all []string
+	for _, name := range files {
+		data, _ := os.ReadFile(name)
+		all = append(all, string(data))
+	}
+	got := strings.Join(all, "")
+	for _, want := range []string{
+		"go test fuzz v1\nstring(\"1e23\")\nfloat64(1e+23)\nfloat32(0.1)\nuint64(42)\n",
+		"go test fuzz v1\nmath.Float64frombits(0x8000000000000000)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in corpus:\n%s", want, got)
+		}
+	}
+}
+
+func TestHardCaseAt(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	found := 0
+	for i := 0; i < 2000; i++ {
+		f, maxDigits, prec := Float64, 17, uint(90)
+		if i%2 == 1 {
+			f, maxDigits, prec = Float32, 9, 48
+		}
+		exp, digits, index := rnd.Intn(4000)-2000, rnd.Intn(100), rnd.Uint64()
+		c, ok := HardCaseAt(f, Midpoints, exp, digits, index, maxDigits, prec)
+		if !ok {
+			continue
+		}
+		found++
+		if c2, _ := HardCaseAt(f, Midpoints, exp, digits, index, maxDigits, prec); c2 != c {
+			t.Errorf("HardCaseAt is not deterministic: %+v != %+v", c, c2)
+		}
+		eps := caseEps(f, Midpoints, c.X, c.N, c.K)
+		if math.Abs(eps-c.Eps) > 1e-9*math.Abs(eps) {
+			t.Errorf("invalid case %+v (eps=%g)", c, eps)
+		}
+	}
+	t.Logf("%d hard cases found", found)
+	if found < 400 {
+		t.Errorf("too few cases")
+	}
+
+	// The first indices are the cases farthest below X,
+	// which must be within the precision.
+	for _, f := range []Format{Float32, Float64} {
+		prec := f.MantBits + 24
+		for exp := 0; exp <= 300; exp += 7 {
+			for digits := 0; digits <= 16; digits++ {
+				for index := uint64(0); index < 4; index++ {
+					c, ok := HardCaseAt(f, Midpoints, exp, digits, index, 17, prec)
+					if ok && math.Abs(c.Eps) >= math.Ldexp(1, -int(prec)) {
+						t.Fatalf("%s: HardCaseAt(%d, %d, %d) = %+v is beyond 2^-%d",
+							f.Name, exp, digits, index, c, prec)
+					}
+				}
+			}
+		}
+	}
+}
+
+// FuzzParseFloat is an example of fuzz test seeded with hard cases.
+func FuzzParseFloat(f *testing.F) {
+	AddSeeds(f, Float64, Midpoints, DecimalSeed, 17, 100, 100)
+	f.Fuzz(func(t *testing.T, s string) {
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return
+		}
+		if y, _ := strconv.ParseFloat(strconv.FormatFloat(x, 'e', -1, 64), 64); y != x {
+			t.Errorf("%q: incorrect round trip", s)
+		}
+	})
+}
+
+// FuzzParseHardCase is an example of fuzz test exploring
+// only hard cases, using HardCaseAt.
+func FuzzParseHardCase(f *testing.F) {
+	f.Add(0, uint8(16), uint64(0))
+	f.Add(-1, uint8(16), uint64(12345))
+	f.Fuzz(func(t *testing.T, exp int, digits uint8, index uint64) {
+		c, ok := HardCaseAt(Float64, Midpoints, exp, int(digits), index, 17, 80)
+		if !ok {
+			return
+		}
+		s := strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)
+		want := c.X
+		if c.Eps > 0 || c.Eps == 0 && math.Float64bits(c.X)&1 == 1 {
+			want = math.Nextafter(c.X, math.Inf(1))
+		}
+		if x, _ := strconv.ParseFloat(s, 64); x != want {
+			t.Errorf("ParseFloat(%q) = %v, want %v", s, x, want)
+		}
+	})
+}
@@ -1,4 +1,4 @@
 module github.com/remyoudompheng/fptest
 
-go 1.12
+go 1.18
 
@@ -0,0 +1,358 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+// Grisu-family algorithms format a float64 using 64-bit
+// fixed-point arithmetic and cached powers of ten.
+//
+// grisuPrecision is the precision used to enumerate candidates, derived
+// from the error of the scaled numbers. The cached power c is rounded
+// to 64 bits (error at most 1/2 unit in its last place) and the product
+// by a normalized 64-bit mantissa is rounded to its high 64 bits, so
+// the scaled boundaries and w are off by less than 1 unit, for values
+// of at least 2^62 - 2^10 units. The digit generation is exact, and:
+//
+//   - Grisu2 shrinks the boundaries by 1 unit, so it misses the shortest
+//     decimal D only if D is within 2 units of a boundary;
+//   - Grisu3 widens them by 1 unit and gives up if the digits are within
+//     2 units of the upper boundary or 4 units of the lower one, so D is
+//     within 4 units of a boundary;
+//   - Grisu3 also gives up if w±1 unit contains the middle (n+1/2)×10^k
+//     of the last two candidate decimals, which is then within 2 units
+//     of w.
+//
+// The relative difference is thus less than 4/(2^62-2^10) < 2^-59
+// between a midpoint of x (a boundary) and D, or between x and the
+// half-decimal.
+const grisuPrecision = 59
+
+// A GrisuFailure describes why a Grisu algorithm cannot
+// be used for a given float64.
+type GrisuFailure int
+
+const (
+	// Grisu3Bail means that Grisu3 gives up and a fallback is needed.
+	Grisu3Bail GrisuFailure = 1 << iota
+	// Grisu2Long means that Grisu2 returns more digits than necessary.
+	Grisu2Long
+)
+
+func (g GrisuFailure) String() string {
+	switch g {
+	case Grisu3Bail:
+		return "grisu3-bail"
+	case Grisu2Long:
+		return "grisu2-long"
+	case Grisu3Bail | Grisu2Long:
+		return "grisu3-bail,grisu2-long"
+	}
+	return "ok"
+}
+
+// GrisuFailures enumerates float64 numbers mant×2^e2 (where mant has 53 bits
+// or 52 bits if denormal is set) for which Grisu2 produces a non-shortest
+// result or Grisu3 gives up, and where the decimal number causing the
+// failure has the given number of digits.
+//
+// Candidates are the floats whose midpoints are very close to
+// n×10^k (see AlmostDecimalMidpoint) or which are very close to
+// (n+1/2)×10^k (see AlmostHalfDecimal), within 2^-grisuPrecision,
+// and they are checked by simulating Grisu2 and Grisu3. The list is
+// complete: by the error bound of grisuPrecision, every failing float
+// is a candidate for the decimal with as many digits as the shortest
+// output (Grisu2) or the digits generated by Grisu3. It is reported
+// for its own exponent, except the first float of a binade, whose
+// lower boundary is a midpoint of the binade below: it may be reported
+// for the exponent below (with the denormals for the smallest normal).
+//
+// As in AlmostDecimalMidpoint, the decimal exponent is fixed for
+// each binary exponent, so that decimals near the top of the binade
+// have the given number of digits. The number of failures grows like
+// 10^digits / 32 for each exponent.
+func GrisuFailures(e2 int, digits int, denormal bool, f func(x float64, fail GrisuFailure)) {
+	mantbits := uint(53)
+	if denormal {
+		mantbits = 52
+	}
+	seen := make(map[float64]bool)
+	check := func(x float64) {
+		if seen[x] || math.IsInf(x, 0) {
+			return
+		}
+		seen[x] = true
+		if fail := GrisuCheck(x); fail != 0 {
+			f(x, fail)
+		}
+	}
+	for _, dir := range []int{-1, 0, +1} {
+		AlmostDecimalMidpoint(e2, digits, mantbits, grisuPrecision, dir, denormal,
+			func(x float64, n uint64, k int) {
+				check(x)
+				check(math.Nextafter(x, math.Inf(1)))
+			})
+		AlmostHalfDecimal(e2, digits, mantbits, grisuPrecision, dir, denormal,
+			func(x float64, n uint64, k int) {
+				check(x)
+			})
+	}
+}
+
+// GrisuCheck runs Grisu2 and Grisu3 on a positive float64
+// and reports their failures.
+func GrisuCheck(x float64) GrisuFailure {
+	var fail GrisuFailure
+	if _, _, ok := Grisu3(x); !ok {
+		fail |= Grisu3Bail
+	}
+	d2, _ := Grisu2(x)
+	var buf [32]byte
+	s := strconv.AppendFloat(buf[:0], x, 'e', -1, 64)
+	if len(d2) > countDigits(s) {
+		fail |= Grisu2Long
+	}
+	return fail
+}
+
+// countDigits returns the number of significant digits
+// of a number formatted by strconv.
+func countDigits(s []byte) (digits int) {
+	for _, c := range s {
+		if '0' <= c && c <= '9' {
+			digits++
+		}
+		if c == 'e' {
+			return
+		}
+	}
+	return
+}
+
+// diyFp is a 64-bit floating-point number f×2^e.
+type diyFp struct {
+	f uint64
+	e int
+}
+
+func diyFromFloat(x float64) diyFp {
+	b := math.Float64bits(x)
+	mant, exp := b&(1<<52-1), int(b>>52)&0x7ff
+	if exp == 0 {
+		return diyFp{mant, -1074}
+	}
+	return diyFp{mant | 1<<52, exp - 1075}
+}
+
+func (x diyFp) normalize() diyFp {
+	s := bits.LeadingZeros64(x.f)
+	return diyFp{x.f << uint(s), x.e - s}
+}
+
+// mul returns x×y rounded to 64 bits (half-up).
+func (x diyFp) mul(y diyFp) diyFp {
+	hi, lo := bits.Mul64(x.f, y.f)
+	return diyFp{hi + lo>>63, x.e + y.e + 64}
+}
+
+// boundaries returns the normalized midpoints between x
+// and its neighbours. The closer lower boundary of powers
+// of two is detected as in double-conversion if dconv is set,
+// otherwise as in Loitsch's reference code.
+func boundaries(x float64, dconv bool) (lo, hi diyFp) {
+	v := diyFromFloat(x)
+	hi = diyFp{v.f<<1 + 1, v.e - 1}.normalize()
+	closer := v.f == 1<<52
+	if dconv {
+		closer = closer && v.e != -1074
+	}
+	if closer {
+		lo = diyFp{v.f<<2 - 1, v.e - 2}
+	} else {
+		lo = diyFp{v.f<<1 - 1, v.e - 1}
+	}
+	lo.f <<= uint(lo.e - hi.e)
+	lo.e = hi.e
+	return
+}
+
+// grisuPowers are the cached powers 10^(-348+8i) rounded
+// to 64-bit mantissas.
+var grisuPowers = func() (p [87]diyFp) {
+	ten := big.NewInt(10)
+	for i := range p {
+		d := -348 + 8*i
+		pow := new(big.Int).Exp(ten, big.NewInt(int64(abs(d))), nil)
+		f := new(big.Float).SetPrec(64)
+		if d < 0 {
+			f.Quo(big.NewFloat(1), new(big.Float).SetInt(pow))
+		} else {
+			f.SetInt(pow)
+		}
+		exp := f.MantExp(f)
+		mant, _ := f.SetMantExp(f, 64).Uint64()
+		p[i] = diyFp{mant, exp - 64}
+	}
+	return
+}()
+
+// Grisu3 runs the Grisu3 algorithm as implemented in the
+// double-conversion library. It returns the shortest decimal
+// representation digits×10^exp of x, or ok=false if it gives up.
+func Grisu3(x float64) (digits []byte, exp int, ok bool) {
+	w := diyFromFloat(x).normalize()
+	lo, hi := boundaries(x, true)
+	// Find a cached power such that scaled exponents
+	// are in [-60, -32].
+	minExp := -60 - (w.e + 64)
+	k := int(math.Ceil(float64(minExp+63) * log2overlog10))
+	idx := (348+k-1)/8 + 1
+	mk := -348 + 8*idx
+	c := grisuPowers[idx]
+	w, lo, hi = w.mul(c), lo.mul(c), hi.mul(c)
+
+	var unit uint64 = 1
+	tooLow := lo.f - unit
+	tooHigh := hi.f + unit
+	unsafe := tooHigh - tooLow
+	shift := uint(-w.e)
+	one := uint64(1) << shift
+	integrals := uint32(tooHigh >> shift)
+	fractionals := tooHigh & (one - 1)
+	divisor, kappa := biggestPow10(integrals, 64-int(shift))
+	for kappa > 0 {
+		digits = append(digits, byte('0'+integrals/divisor))
+		integrals %= divisor
+		kappa--
+		rest := uint64(integrals)<<shift + fractionals
+		if rest < unsafe {
+			ok = roundWeed(digits, tooHigh-w.f, unsafe, rest, uint64(divisor)<<shift, unit)
+			return digits, kappa - mk, ok
+		}
+		divisor /= 10
+	}
+	for {
+		fractionals *= 10
+		unit *= 10
+		unsafe *= 10
+		digits = append(digits, byte('0'+fractionals>>shift))
+		fractionals &= one - 1
+		kappa--
+		if fractionals < unsafe {
+			ok = roundWeed(digits, (tooHigh-w.f)*unit, unsafe, fractionals, one, unit)
+			return digits, kappa - mk, ok
+		}
+	}
+}
+
+// biggestPow10 returns the largest power of ten
+// less than or equal to n, which has at most nbits bits,
+// and its exponent plus one.
+func biggestPow10(n uint32, nbits int) (uint32, int) {
+	guess := (nbits+1)*1233>>12 + 1
+	if n < smallPow10[guess] {
+		guess--
+	}
+	return smallPow10[guess], guess
+}
+
+var smallPow10 = [...]uint32{0, 1, 10, 100, 1000, 10000, 100000,
+	1000000, 10000000, 100000000, 1000000000}
+
+// roundWeed adjusts the last digit towards w and checks
+// that the result is provably correct.
+func roundWeed(digits []byte, distTooHighW, unsafe, rest, tenKappa, unit uint64) bool {
+	small := distTooHighW - unit
+	big := distTooHighW + unit
+	for rest < small && unsafe-rest >= tenKappa &&
+		(rest+tenKappa < small || small-rest >= rest+tenKappa-small) {
+		digits[len(digits)-1]--
+		rest += tenKappa
+	}
+	if rest < big && unsafe-rest >= tenKappa &&
+		(rest+tenKappa < big || big-rest > rest+tenKappa-big) {
+		return false
+	}
+	return 2*unit <= rest && rest <= unsafe-4*unit
+}
+
+// Grisu2 runs the Grisu2 algorithm as implemented in Loitsch's
+// reference code (and many derived JSON libraries). It returns a
+// decimal representation digits×10^exp of x which always parses
+// back to x, but is not necessarily the shortest.
+func Grisu2(x float64) (digits []byte, exp int) {
+	lo, hi := boundaries(x, false)
+	dk := float64(-61-hi.e)*log2overlog10 + 347
+	k := int(dk)
+	if dk-float64(k) > 0 {
+		k++
+	}
+	idx := k>>3 + 1
+	K := 348 - 8*idx
+	c := grisuPowers[idx]
+	w := diyFromFloat(x).normalize().mul(c)
+	hi, lo = hi.mul(c), lo.mul(c)
+	lo.f++
+	hi.f--
+	delta := hi.f - lo.f
+
+	shift := uint(-hi.e)
+	one := uint64(1) << shift
+	wpw := hi.f - w.f
+	p1 := uint32(hi.f >> shift)
+	p2 := hi.f & (one - 1)
+	kappa := len(strconv.FormatUint(uint64(p1), 10))
+	for kappa > 0 {
+		div := smallPow10[kappa]
+		d := p1 / div
+		p1 %= div
+		if d != 0 || len(digits) > 0 {
+			digits = append(digits, byte('0'+d))
+		}
+		kappa--
+		rest := uint64(p1)<<shift + p2
+		if rest <= delta {
+			digits = grisuRound(digits, delta, rest, uint64(smallPow10[kappa+1])<<shift, wpw)
+			return digits, K + kappa
+		}
+	}
+	for {
+		p2 *= 10
+		delta *= 10
+		d := byte(p2 >> shift)
+		if d != 0 || len(digits) > 0 {
+			digits = append(digits, '0'+d)
+		}
+		p2 &= one - 1
+		kappa--
+		if p2 < delta {
+			var p10 uint64
+			if -kappa < 20 {
+				p10 = pow10u64(-kappa)
+			}
+			digits = grisuRound(digits, delta, p2, one, wpw*p10)
+			return digits, K + kappa
+		}
+	}
+}
+
+// grisuRound moves the last digit towards w.
+func grisuRound(digits []byte, delta, rest, tenKappa, wpw uint64) []byte {
+	for rest < wpw && delta-rest >= tenKappa &&
+		(rest+tenKappa < wpw || wpw-rest > rest+tenKappa-wpw) {
+		digits[len(digits)-1]--
+		rest += tenKappa
+	}
+	return digits
+}
+
+func pow10u64(n int) uint64 {
+	p := uint64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
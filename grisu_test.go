@@ -0,0 +1,141 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestGrisuPowers(t *testing.T) {
+	// First and last entries of the double-conversion table.
+	if p := grisuPowers[0]; p != (diyFp{0xfa8fd5a0081c0288, -1220}) {
+		t.Errorf("10^-348: got %x", p)
+	}
+	if p := grisuPowers[86]; p != (diyFp{0xaf87023b9bf0ee6b, 1066}) {
+		t.Errorf("10^340: got %x", p)
+	}
+}
+
+func TestGrisu(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	bails, long := 0, 0
+	const N = 200000
+	for i := 0; i < N; i++ {
+		x := math.Float64frombits(rnd.Uint64() &^ (1 << 63))
+		if math.IsInf(x, 0) || math.IsNaN(x) || x == 0 {
+			continue
+		}
+		want := strconv.FormatFloat(x, 'e', -1, 64)
+		d3, e3, ok := Grisu3(x)
+		if ok {
+			if s := string(d3) + "e" + strconv.Itoa(e3); !sameFloatString(s, want) {
+				t.Errorf("Grisu3(%v) = %s, want %s", x, s, want)
+			}
+		} else {
+			bails++
+		}
+		d2, e2 := Grisu2(x)
+		s := string(d2) + "e" + strconv.Itoa(e2)
+		if y, _ := strconv.ParseFloat(s, 64); y != x {
+			t.Errorf("Grisu2(%v) = %s does not round trip", x, s)
+		}
+		if len(d2) > countDigits([]byte(want)) {
+			long++
+		}
+	}
+	t.Logf("Grisu3 bails: %d/%d, Grisu2 non-shortest: %d/%d", bails, N, long, N)
+	if bails == 0 || bails > N/50 {
+		t.Errorf("unexpected Grisu3 failure rate")
+	}
+}
+
+// sameFloatString checks whether decimal strings s and t
+// have the same value.
+func sameFloatString(s, t string) bool {
+	x, _ := new(big.Float).SetPrec(200).SetString(s)
+	y, _ := new(big.Float).SetPrec(200).SetString(t)
+	return x != nil && y != nil && x.Cmp(y) == 0
+}
+
+func TestGrisuFailures(t *testing.T) {
+	// Check that GrisuFailures is complete for failures caused by
+	// short decimals: a failure is caused by a decimal close to a
+	// boundary of the float or a half-decimal close to the float,
+	// so the floats around every decimal and half-decimal with at
+	// most maxDigits digits include all such failures.
+	// Since the decimal exponent is fixed for each binary exponent,
+	// decimals with N digits may be enumerated with digits = N+1,
+	// and floats above a midpoint may be in the next binade.
+	const maxDigits = 5
+	found := make(map[float64]GrisuFailure)
+	exps := []int{-1000, -300, -60, -2, 40, 100, 500, 900}
+	for _, e2 := range exps {
+		for _, e := range []int{e2 - 1, e2} {
+			for digits := 1; digits <= maxDigits+1; digits++ {
+				GrisuFailures(e, digits, false, func(x float64, fail GrisuFailure) {
+					if GrisuCheck(x) != fail {
+						t.Errorf("inconsistent result for %v", x)
+					}
+					found[x] |= fail
+				})
+			}
+		}
+	}
+	t.Logf("%d failures found", len(found))
+
+	checked, failures := 0, 0
+	for _, e2 := range exps {
+		lo, hi := math.Ldexp(1<<52, e2), math.Ldexp(1<<53, e2)
+		k0 := int(math.Floor(math.Log10(lo))) - maxDigits
+		for k := k0; k <= k0+maxDigits+1; k++ {
+			for n := 1; n < 10*pow10i(maxDigits); n++ {
+				// n×10^(k-1) is a decimal (n%10 = 0)
+				// or a half-decimal (n%10 = 5).
+				if n%10 != 0 && n%10 != 5 || n%10 == 0 && n >= pow10i(maxDigits+1) {
+					continue
+				}
+				y, _ := strconv.ParseFloat(strconv.Itoa(n)+"e"+strconv.Itoa(k-1), 64)
+				if y < lo || y >= hi {
+					continue
+				}
+				for _, z := range []float64{math.Nextafter(y, 0), y, math.Nextafter(y, math.Inf(1))} {
+					if _, e := math.Frexp(z); e-53 != e2 {
+						continue
+					}
+					checked++
+					// Failures are caused by decimals with as many digits
+					// as the (failed) Grisu3 output, or the shortest output.
+					fail := GrisuCheck(z)
+					d3, _, _ := Grisu3(z)
+					short := countDigits([]byte(strconv.FormatFloat(z, 'e', -1, 64)))
+					if !(fail&Grisu3Bail != 0 && len(d3) <= maxDigits ||
+						fail&Grisu2Long != 0 && short <= maxDigits) {
+						continue
+					}
+					failures++
+					if found[z] != fail {
+						t.Errorf("%v (near %de%d): %s not enumerated", z, n, k-1, fail)
+					}
+				}
+			}
+		}
+	}
+	t.Logf("%d floats checked, %d failures", checked, failures)
+
+	// The lower boundary of the smallest normal float is not
+	// a midpoint in Loitsch's Grisu2 (see boundaries), so it is
+	// not a candidate: it must not fail.
+	if fail := GrisuCheck(math.Ldexp(1, -1022)); fail != 0 {
+		t.Errorf("smallest normal float: %s", fail)
+	}
+}
+
+func pow10i(n int) int {
+	p := 1
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,110 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ExpandMidpoint calls fn with long decimal strings around the
+// midpoint between x and the next float of format f, with the result
+// expected from a correctly rounded parser (ties to even).
+//
+// Midpoints are dyadic, so they have a finite decimal expansion D
+// (up to 767 significant digits for float64). The strings are D itself
+// padded with zeros to pos digits (an exact tie), and the first pos
+// digits of D followed by a digit lower or higher than the next digit
+// of D, and by the rest of D (or a final 1 if it is zero), a sticky
+// tail which does not change the rounding. Thus the rounding of these
+// strings is decided by the digit just after position pos. If the
+// next digit of D is 0 (or 9), there is no string below (or above).
+// Positions 19, 40 and 800 exercise truncation to a uint64 mantissa,
+// to a wider buffer, and the sticky digit of strconv's 800-digit
+// decimal.
+//
+// The strings are of the form "NeK" where N is an integer.
+func ExpandMidpoint(f Format, x float64, pos int, fn func(s string, want float64)) {
+	// Half an ulp may not be a float64.
+	_, e := math.Frexp(x)
+	e -= int(f.MantBits)
+	if e < f.MinExp {
+		e = f.MinExp
+	}
+	half, _ := new(big.Float).SetMantExp(big.NewFloat(1), e-1).Rat(nil)
+	mid := new(big.Rat).SetFloat64(x)
+	mid.Add(mid, half)
+	D, K := exactDecimal(mid)
+	emit := func(digits string, k int) {
+		n, _ := new(big.Int).SetString(digits, 10)
+		v := new(big.Rat).SetInt(n)
+		if k >= 0 {
+			v.Mul(v, new(big.Rat).SetInt(pow10Big(k)))
+		} else {
+			v.Quo(v, new(big.Rat).SetInt(pow10Big(-k)))
+		}
+		fn(digits+"e"+strconv.Itoa(k), f.round(v))
+	}
+	// The digits of D, padded to pos+1 digits.
+	ds := D.String()
+	if len(ds) <= pos {
+		K -= pos + 1 - len(ds)
+		ds += strings.Repeat("0", pos+1-len(ds))
+	}
+	// The tie, with at least pos digits.
+	if tie := strings.TrimRight(ds, "0"); len(tie) < pos {
+		emit(ds[:pos], K+len(ds)-pos)
+	} else {
+		emit(tie, K+len(ds)-len(tie))
+	}
+	prefix, next, tail, k := ds[:pos], ds[pos], ds[pos+1:], K
+	if strings.Trim(tail, "0") == "" {
+		tail += "1"
+		k--
+	}
+	if next > '0' {
+		emit(prefix+string(next-1)+tail, k)
+	}
+	if next < '9' {
+		emit(prefix+string(next+1)+tail, k)
+	}
+}
+
+// exactDecimal returns the integer D without trailing zeros
+// and the exponent K such that r = D×10^K, for a positive
+// dyadic rational r.
+func exactDecimal(r *big.Rat) (D *big.Int, K int) {
+	D = new(big.Int).Set(r.Num())
+	// The denominator is 2^e: multiply by 5^e.
+	e := r.Denom().BitLen() - 1
+	D.Mul(D, pow5Big(e))
+	K = -e
+	s := D.String()
+	if t := strings.TrimRight(s, "0"); len(t) < len(s) {
+		D.SetString(t, 10)
+		K += len(s) - len(t)
+	}
+	return D, K
+}
+
+// LongDecimals calls fn with the long decimal strings given by
+// ExpandMidpoint for each position, around the midpoints of the count
+// hardest cases of format f (see BestFirst). The first digits of
+// these strings are the digits of the hard case, so that a parser
+// truncating them cannot decide the rounding. Each string is
+// given once, although the ties of several positions or cases
+// may be the same.
+func LongDecimals(f Format, digits int, precision uint, count int, positions []int,
+	fn func(c HardCase, s string, want float64)) {
+	seen := make(map[string]bool)
+	BestFirst(f, Midpoints, digits, precision, count, func(c HardCase) {
+		for _, pos := range positions {
+			ExpandMidpoint(f, c.X, pos, func(s string, want float64) {
+				if !seen[s] {
+					seen[s] = true
+					fn(c, s, want)
+				}
+			})
+		}
+	})
+}
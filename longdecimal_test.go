@@ -0,0 +1,99 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExpandMidpoint(t *testing.T) {
+	for _, c := range []struct {
+		f Format
+		x float64
+	}{
+		{Float64, 1},
+		{Float64, 0.1},
+		{Float64, 1e300},
+		{Float64, math.MaxFloat64},
+		{Float64, math.SmallestNonzeroFloat64},
+		{Float64, math.Ldexp(1, -1022)},
+		{Float32, float64(float32(0.1))},
+		{Float32, math.SmallestNonzeroFloat32},
+		{Float32, math.MaxFloat32},
+	} {
+		next := c.f.next(c.x)
+		even := c.x
+		if mantOdd(c.f, c.x) {
+			even = next
+		}
+		mid := new(big.Rat).SetFloat64(c.x)
+		if !math.IsInf(next, 0) {
+			mid.Add(mid, new(big.Rat).SetFloat64(next)).Quo(mid, big.NewRat(2, 1))
+		}
+		seen := make(map[string]int)
+		for _, pos := range []int{19, 40, 800} {
+			var got []string
+			var tie string
+			ExpandMidpoint(c.f, c.x, pos, func(s string, want float64) {
+				got = append(got, s)
+				v, ok := new(big.Rat).SetString(s)
+				if !ok {
+					t.Fatalf("invalid decimal %s", s)
+				}
+				digits := s[:strings.IndexByte(s, 'e')]
+				if len(got) == 1 {
+					tie = s
+					if !math.IsInf(next, 0) && v.Cmp(mid) != 0 || want != even {
+						t.Errorf("%b pos=%d: tie %s gives %b, want %b", c.x, pos, s, want, even)
+					}
+					if len(digits) < pos {
+						t.Errorf("%b pos=%d: tie %s is too short", c.x, pos, s)
+					}
+					return
+				}
+				// The tie is the exact midpoint, even above the
+				// largest float.
+				tv, _ := new(big.Rat).SetString(tie)
+				below := v.Cmp(tv) < 0
+				if below && want != c.x || !below && want != next {
+					t.Errorf("%b pos=%d: %s gives %b", c.x, pos, s, want)
+				}
+				// The first pos digits are those of the tie,
+				// and the next one differs.
+				tieDigits := tie[:strings.IndexByte(tie, 'e')]
+				k, _ := strconv.Atoi(s[len(digits)+1:])
+				tk, _ := strconv.Atoi(tie[len(tieDigits)+1:])
+				if len(digits)+k != len(tieDigits)+tk {
+					t.Fatalf("%b pos=%d: %s is not aligned with %s", c.x, pos, s, tie)
+				}
+				if tk > k {
+					tieDigits += strings.Repeat("0", tk-k)
+				}
+				if len(digits) < pos+2 || len(tieDigits) <= pos ||
+					digits[:pos] != tieDigits[:pos] || digits[pos] == tieDigits[pos] {
+					t.Errorf("%b pos=%d: %s is not decided at digit %d of %s", c.x, pos, s, pos+1, tie)
+				}
+				if p, ok := seen[s]; ok && p != pos {
+					t.Errorf("%b: %s is given for positions %d and %d", c.x, s, p, pos)
+				}
+				seen[s] = pos
+			})
+			if len(got) < 2 || len(got) > 3 {
+				t.Errorf("%b pos=%d: got %d strings", c.x, pos, len(got))
+			}
+		}
+	}
+}
+
+// mantOdd returns whether the mantissa of x in format f is odd.
+func mantOdd(f Format, x float64) bool {
+	_, e := math.Frexp(x)
+	e -= int(f.MantBits)
+	if e < f.MinExp {
+		e = f.MinExp
+	}
+	m := uint64(math.Ldexp(x, -e))
+	return m%2 == 1
+}
@@ -0,0 +1,131 @@
+package fptest
+
+import (
+	"math"
+	"sort"
+)
+
+// A Reproducer is the simplest failing case found by Minimize.
+type Reproducer struct {
+	HardCase
+	Digits   int
+	Denormal bool
+	// Precision is the smallest precision at which the bug reproduces,
+	// among the cases checked: the difficulty floor(-log2 |Eps|) of the
+	// easiest failing case found. Failing cases of lower difficulty may
+	// exist beyond the budget of the search (see Minimize). It is 0 for
+	// an exact case (Eps = 0), which is only enumerated by direction 0
+	// of the enumerators.
+	Precision uint
+	// Checks is the number of calls to the checker.
+	Checks int
+}
+
+// Minimize searches the hard cases of format f and mode m for the
+// simplest case failing check, which returns false when an
+// implementation gives an incorrect result. The search starts from
+// a failing case c with the given number of digits and looks, in this
+// order, for fewer digits, a smaller binary exponent |Exp| and a lower
+// difficulty (a larger |Eps|). Each step walks the hardest cases first,
+// and the search calls check at most budget times per step.
+//
+// The search is not exhaustive: the last step only checks the budget
+// hardest cases of the exponent, so the easiest failing case, and the
+// Precision of the result, depend on the budget. A larger budget may
+// find an easier failing case and a smaller precision.
+//
+// Minimize panics if c does not fail.
+func Minimize(f Format, m Mode, c HardCase, digits int, check func(c HardCase) bool, budget int) Reproducer {
+	if check(c) {
+		panic("the case does not fail")
+	}
+	r := Reproducer{HardCase: c, Digits: digits, Checks: 1}
+	all := f.exponents()
+
+	// Fewer digits, any exponent.
+	for d := 1; d < digits; d++ {
+		found := false
+		r.Checks += f.failures(m, d, all, check, budget, func(c HardCase, denormal bool) bool {
+			r.HardCase, r.Digits, r.Denormal = c, d, denormal
+			found = true
+			return false
+		})
+		if found {
+			break
+		}
+	}
+
+	// Smaller exponents, by increasing |Exp|.
+	perExp := budget / 100
+	if perExp < 10 {
+		perExp = 10
+	}
+	checks := 0
+	for _, e := range all {
+		if abs(e.exp) >= abs(r.Exp) || checks >= budget {
+			break
+		}
+		found := false
+		checks += f.failures(m, r.Digits, []expMode{e}, check, perExp, func(c HardCase, denormal bool) bool {
+			r.HardCase, r.Denormal = c, denormal
+			found = true
+			return false
+		})
+		if found {
+			break
+		}
+	}
+	r.Checks += checks
+
+	// The easiest failing case for that exponent.
+	e := expMode{r.Exp, r.Denormal}
+	r.Checks += f.failures(m, r.Digits, []expMode{e}, check, budget, func(c HardCase, denormal bool) bool {
+		if math.Abs(c.Eps) > math.Abs(r.Eps) {
+			r.HardCase = c
+		}
+		return true
+	})
+	if r.Eps != 0 {
+		r.Precision = uint(math.Floor(-math.Log2(math.Abs(r.Eps))))
+	}
+	return r
+}
+
+// An expMode is a binary exponent, for normal or denormal numbers.
+type expMode struct {
+	exp      int
+	denormal bool
+}
+
+// exponents returns the exponents of format f by increasing
+// absolute value, the denormal exponent coming last.
+func (f Format) exponents() []expMode {
+	var exps []expMode
+	for e2 := f.MinExp; e2 <= f.MaxExp; e2++ {
+		exps = append(exps, expMode{e2, false})
+	}
+	sort.SliceStable(exps, func(i, j int) bool { return abs(exps[i].exp) < abs(exps[j].exp) })
+	return append(exps, expMode{f.MinExp, true})
+}
+
+// failures walks the cases of format f and mode m for the given
+// exponents from the hardest, checking at most budget cases, and calls
+// fn for each failing case until it returns false. It returns the
+// number of checks.
+func (f Format) failures(m Mode, digits int, exps []expMode, check func(c HardCase) bool, budget int,
+	fn func(c HardCase, denormal bool) bool) int {
+	var q streamHeap
+	for _, e := range exps {
+		// A precision of 1 bit walks all cases up to a factor 2.
+		q.add(f.target(m, e.exp, digits, e.denormal), e.exp, 1)
+	}
+	checks := 0
+	q.walk(func(c HardCase, denormal bool) bool {
+		checks++
+		if !check(c) && !fn(c, denormal) {
+			return false
+		}
+		return checks < budget
+	})
+	return checks
+}
@@ -0,0 +1,54 @@
+package fptest
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	// A float32 parser going through float64 fails
+	// on decimals very close to midpoints.
+	check := func(c HardCase) bool {
+		s := strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)
+		x32, _ := strconv.ParseFloat(s, 32)
+		x64, _ := strconv.ParseFloat(s, 64)
+		return x32 == float64(float32(x64))
+	}
+	const digits = 14
+	var start HardCase
+	found := false
+	BestFirst(Float32, Midpoints, digits, 60, 1000, func(c HardCase) {
+		if !found && !check(c) && abs(c.Exp) > 50 {
+			start, found = c, true
+		}
+	})
+	if !found {
+		t.Fatal("no failing case")
+	}
+	r := Minimize(Float32, Midpoints, start, digits, check, 20000)
+	t.Logf("from %+v", start)
+	t.Logf("to %+v", r)
+	if check(r.HardCase) {
+		t.Errorf("%+v does not fail", r)
+	}
+	// Fewer digits come first.
+	if r.Digits > digits || r.Digits == digits && abs(r.Exp) > abs(start.Exp) {
+		t.Errorf("%+v is not simpler", r)
+	}
+	// The double rounding needs a decimal within half
+	// a float64 ulp of a float32 midpoint.
+	if r.Precision < 53 || r.Precision > 60 {
+		t.Errorf("unexpected precision %d", r.Precision)
+	}
+}
+
+func TestMinimizeExact(t *testing.T) {
+	// The exact midpoint 16777217 between float32 numbers
+	// is the only failing case: its precision is 0.
+	c := HardCase{X: 1 << 24, Exp: 1, N: 16777217, K: 0}
+	check := func(c HardCase) bool { return c.N != 16777217 || c.K != 0 }
+	r := Minimize(Float32, Midpoints, c, 8, check, 1000)
+	if r.HardCase != c || r.Precision != 0 {
+		t.Errorf("got %+v", r)
+	}
+}
@@ -0,0 +1,108 @@
+package fptest
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// AlmostRoundingProduct enumerates decimal numbers n×10^k, where
+// n < 10^digits, which are hard to parse using a fixed-width product.
+//
+// Such parsers shift n left to a 64-bit integer n', multiply it by a
+// power of ten truncated to width bits (64 or 128), and round the product
+// P = n' × T to its 54 leading bits (53 bits of mantissa and a rounding
+// bit). The table entry T is taken from pow10wide (k >= 0) or
+// invpow10wide (k < 0). Since T is not exact, the product is only
+// usable if its trailing bits are not too close to all zeros or all
+// ones: numbers are returned when the remainder of P modulo the weight
+// of the rounding bit is within 2^-precision of a multiple of that weight.
+//
+// The truncation error of T amounts to less than 2^(55-width) units of
+// the rounding bit, so precision = width-55 lists all inputs where the
+// product alone cannot decide rounding. Powers of ten which are exact
+// in the table are skipped since their products are exact.
+//
+// The callback receives the correctly rounded float64 value of n×10^k.
+// Cases are enumerated by increasing bit length of n.
+func AlmostRoundingProduct(k int, digits int, width uint, precision uint,
+	f func(x float64, n uint64, k int)) {
+	if width != 64 && width != 128 {
+		panic(fmt.Sprintf("unsupported product width %d", width))
+	}
+	if digits <= 0 || digits > 19 {
+		panic(fmt.Sprintf("unsupported number of digits %d", digits))
+	}
+	var t [2]uint64
+	switch {
+	case k >= 0 && k < len(pow10wide):
+		if pow5Big(k).BitLen() <= int(width) {
+			return
+		}
+		t = pow10wide[k]
+	case k < 0 && -k < len(invpow10wide):
+		t = invpow10wide[-k]
+	default:
+		return
+	}
+	T := new(big.Int).SetUint64(t[0])
+	if width == 128 {
+		T.Lsh(T, 64).Or(T, new(big.Int).SetUint64(t[1]))
+	}
+
+	maxN := pow10u64(digits) - 1
+	pk := new(big.Rat).SetFrac(pow10Big(abs(k)), big.NewInt(1))
+	if k < 0 {
+		pk.Inv(pk)
+	}
+	for b := uint(1); b <= uint(bits.Len64(maxN)); b++ {
+		// The product has L = width+63 or width+64 bits and is rounded
+		// at bit sh = L-54. We look for fractions J/n close to
+		// X = T × 2^(64-b) / 2^sh, that is, such that n'×T/2^sh is
+		// close to the integer J.
+		for L := width + 63; L <= width+64; L++ {
+			sh := L - 54
+			num := T
+			den := new(big.Int).Lsh(big.NewInt(1), sh+b-64)
+			for _, dir := range []int{-1, 0, +1} {
+				// J has 54 bits, so a relative difference 2^-(precision+53)
+				// contains all cases.
+				r1, r2 := ratRange(num, den, precision+53, dir, b)
+				for r := r1; r.Less(r2); r.Next() {
+					a, d := r.Fraction()
+					multiples(a, d, b, false, func(J, n uint64) {
+						if n > maxN || !productNearBoundary(n, b, T, L, J, precision) {
+							return
+						}
+						x, _ := new(big.Rat).Mul(new(big.Rat).SetUint64(n), pk).Float64()
+						f(x, n, k)
+					})
+				}
+			}
+		}
+	}
+}
+
+// productNearBoundary checks that (n << (64-b)) × T has L bits
+// and is within 2^-precision of J × 2^(L-54), relative to 2^(L-54).
+// Since ratRange may return a slightly larger interval, the same n
+// can appear with several values of J: only the nearest one matches.
+func productNearBoundary(n uint64, b uint, T *big.Int, L uint, J uint64, precision uint) bool {
+	p := new(big.Int).SetUint64(n << (64 - b))
+	p.Mul(p, T)
+	if uint(p.BitLen()) != L {
+		return false
+	}
+	sh := L - 54
+	rem := new(big.Int).Sub(p, new(big.Int).Lsh(new(big.Int).SetUint64(J), sh))
+	if sh <= precision {
+		// The tolerance is below the unit of the product.
+		return rem.Sign() == 0
+	}
+	rem.Abs(rem)
+	return rem.Cmp(new(big.Int).Lsh(big.NewInt(1), sh-precision)) < 0
+}
+
+func pow10Big(e int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(e)), nil)
+}
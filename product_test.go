@@ -0,0 +1,120 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"testing"
+)
+
+// truncatedParse64 parses n×10^k like a parser using a 64×64-bit
+// product and pow10wide tables, without any fallback.
+func truncatedParse64(n uint64, k int) float64 {
+	var t uint64
+	var e10 int // floor(k × log2(10))
+	if k >= 0 {
+		t = pow10wide[k][0]
+		e10 = pow10Big(k).BitLen() - 1
+	} else {
+		t = invpow10wide[-k][0]
+		e10 = -pow10Big(-k).BitLen()
+	}
+	b := bits.Len64(n)
+	hi, lo := bits.Mul64(n<<uint(64-b), t)
+	// x = (hi, lo) × 2^(e10 - 63 - (64-b))
+	exp := e10 - 63 - (64 - b) + 64
+	if hi < 1<<63 {
+		hi, lo = hi<<1|lo>>63, lo<<1
+		exp--
+	}
+	mant := hi >> 11
+	half := hi&(1<<10) != 0
+	sticky := hi&(1<<10-1) != 0 || lo != 0
+	if half && (sticky || mant&1 == 1) {
+		mant++
+	}
+	return math.Ldexp(float64(mant), exp+11)
+}
+
+func TestAlmostRoundingProduct(t *testing.T) {
+	// Compare with all 4-digit decimals.
+	const digits = 4
+	for _, width := range []uint{64, 128} {
+		for _, k := range []int{-300, -123, -22, -5, 30, 77, 250} {
+			if k >= 0 && pow5Big(k).BitLen() <= int(width) {
+				// exact table entry
+				continue
+			}
+			found := make(map[uint64]bool)
+			AlmostRoundingProduct(k, digits, width, 8, func(x float64, n uint64, kk int) {
+				if kk != k || found[n] {
+					t.Errorf("width=%d: unexpected (n=%d, k=%d)", width, n, kk)
+				}
+				found[n] = true
+				if w := exactFloat64(n, k); w != x {
+					t.Errorf("%de%d: got %v, want %v", n, k, x, w)
+				}
+			})
+			T := new(big.Int)
+			if k >= 0 {
+				T.SetUint64(pow10wide[k][0])
+				if width == 128 {
+					T.Lsh(T, 64).Or(T, new(big.Int).SetUint64(pow10wide[k][1]))
+				}
+			} else {
+				T.SetUint64(invpow10wide[-k][0])
+				if width == 128 {
+					T.Lsh(T, 64).Or(T, new(big.Int).SetUint64(invpow10wide[-k][1]))
+				}
+			}
+			for n := uint64(1); n < 1e4; n++ {
+				p := new(big.Int).SetUint64(n << uint(64-bits.Len64(n)))
+				p.Mul(p, T)
+				// Distance of p to a multiple of 2^sh.
+				sh := uint(p.BitLen() - 54)
+				rem := new(big.Int).Mod(p, new(big.Int).Lsh(big.NewInt(1), sh))
+				if rem.Bit(int(sh-1)) == 1 {
+					rem.Sub(new(big.Int).Lsh(big.NewInt(1), sh), rem)
+				}
+				near := rem.BitLen() <= int(sh-8)
+				if near != found[n] {
+					t.Errorf("width=%d: %de%d enumerated=%v, want %v",
+						width, n, k, found[n], near)
+				}
+			}
+			t.Logf("width=%d k=%d: %d cases", width, k, len(found))
+		}
+	}
+}
+
+func TestAlmostRoundingProductParse(t *testing.T) {
+	// A parser using a 64-bit product fails only on enumerated inputs.
+	const digits = 5
+	for _, k := range []int{-307, -200, -100, -40, -1, 28, 100, 200, 300} {
+		found := make(map[uint64]bool)
+		AlmostRoundingProduct(k, digits, 64, 64-55, func(x float64, n uint64, k int) {
+			found[n] = true
+		})
+		fails := 0
+		for n := uint64(1); n < 1e5; n++ {
+			x := truncatedParse64(n, k)
+			w := exactFloat64(n, k)
+			if x != w {
+				fails++
+				if !found[n] {
+					t.Errorf("%de%d: truncated product gives %v, want %v (not enumerated)",
+						n, k, x, w)
+				}
+			}
+		}
+		t.Logf("k=%d: %d enumerated, %d failures", k, len(found), fails)
+	}
+}
+
+// exactFloat64 returns the float64 nearest to n×10^k.
+func exactFloat64(n uint64, k int) float64 {
+	s, _ := appendE(make([]byte, 32), n, -1, k)
+	r, _ := new(big.Rat).SetString(string(s))
+	x, _ := r.Float64()
+	return x
+}
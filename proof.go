@@ -0,0 +1,347 @@
+package fptest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// A MulShift describes the truncated multiplications performed
+// by Ryū-like shortest formatting algorithms.
+//
+// For each binary exponent e2, the algorithm computes
+//
+//	(k × entry) >> shift
+//
+// where entry is a table value of at most TableBits bits, in place
+// of the exact quotient floor(k × X) for a rational multiplier X
+// (usually 2^e2 / 10^q or 5^i / 2^q). The integer k ranges over
+// all values below 2^InBits (e.g. 4×mantissa+2 in Ryū).
+type MulShift struct {
+	Name string
+	// InBits is the bit length of integers multiplied by table entries.
+	InBits uint
+	// TableBits is the width of table entries.
+	TableBits uint
+	// MinExp and MaxExp are the bounds of the exponent range.
+	MinExp, MaxExp int
+	// Shift returns the right shift applied to products for exponent e2.
+	Shift func(e2 int) uint
+	// Multiplier returns the table entry used for exponent e2
+	// and the exact multiplier X it approximates. The entry divided
+	// by 2^Shift(e2) and X are the bounds of the interval that must not
+	// contain any fraction K/k.
+	Multiplier func(e2 int) (entry *big.Int, exact *big.Rat)
+	// If Sticky is set, the algorithm also relies on the product
+	// being an exact integer if and only if k × X is an integer,
+	// as in round-to-odd products.
+	Sticky bool
+	// If Skip is not nil, exponents for which it returns true
+	// have no multiplication and are not checked.
+	Skip func(e2 int) bool
+}
+
+// A Carry is a counterexample to the correctness of a MulShift:
+// the truncated product of K by the table entry for Exp
+// differs from the exact product.
+type Carry struct {
+	Exp int
+	K   uint64 // the multiplied integer
+	Quo uint64 // the integer between the approximate and exact products
+	// Exact is set if K × X is exactly Quo.
+	Exact bool
+}
+
+func (c Carry) String() string {
+	if c.Exact {
+		return fmt.Sprintf("e2=%d: %d × X == %d", c.Exp, c.K, c.Quo)
+	}
+	return fmt.Sprintf("e2=%d: %d × X crosses %d", c.Exp, c.K, c.Quo)
+}
+
+// Prove checks that truncated products are exact for every exponent
+// in [MinExp, MaxExp] and every integer k < 2^InBits, by walking
+// the Farey interval between the table entry and the exact multiplier.
+//
+// If w is not nil, a certificate is written to w: for each exponent,
+// it lists the two consecutive fractions of the Farey sequence
+// which enclose the interval (proving that no fraction lies inside),
+// or the first counterexamples found.
+func (m *MulShift) Prove(w io.Writer) []Carry {
+	var carries []Carry
+	if w != nil {
+		fmt.Fprintf(w, "# %s: %d-bit inputs, %d-bit table, exponents %d..%d\n",
+			m.Name, m.InBits, m.TableBits, m.MinExp, m.MaxExp)
+	}
+	for e2 := m.MinExp; e2 <= m.MaxExp; e2++ {
+		if m.Skip != nil && m.Skip(e2) {
+			continue
+		}
+		entry, exact := m.Multiplier(e2)
+		shift := m.Shift(e2)
+		if entry.BitLen() > int(m.TableBits) {
+			panic(fmt.Sprintf("%s: table entry for e2=%d has %d bits",
+				m.Name, e2, entry.BitLen()))
+		}
+		approx := new(big.Rat).SetFrac(entry, new(big.Int).Lsh(big.NewInt(1), shift))
+		c, lo, hi := m.proveOne(e2, approx, exact)
+		carries = append(carries, c...)
+		if w == nil {
+			continue
+		}
+		if len(c) == 0 {
+			ln, ld := lo.Fraction()
+			hn, hd := hi.Fraction()
+			fmt.Fprintf(w, "e2=%d shift=%d entry=%#x ok: %d/%d <= bounds < %d/%d\n",
+				e2, shift, entry, ln, ld, hn, hd)
+		}
+		for _, cc := range c {
+			fmt.Fprintf(w, "e2=%d shift=%d entry=%#x FAIL: %s\n",
+				e2, shift, entry, cc)
+		}
+	}
+	return carries
+}
+
+// maxCarries is the maximal number of counterexamples
+// reported for each exponent.
+const maxCarries = 16
+
+// proveOne enumerates fractions K/k between approx and exact.
+// If none is found, it returns consecutive fractions lo < hi
+// of the Farey sequence surrounding the interval.
+func (m *MulShift) proveOne(e2 int, approx, exact *big.Rat) (carries []Carry, lo, hi *Rat) {
+	low, up := approx, exact
+	if up.Cmp(low) < 0 {
+		low, up = up, low
+	}
+	if low.Cmp(up) == 0 {
+		r, _ := NewRatFromBig(low.Num(), low.Denom(), m.InBits)
+		return nil, r, r
+	}
+	// Counterexamples are fractions in (low, up],
+	// or [low, up] for sticky products.
+	l, u := NewRatFromBig(low.Num(), low.Denom(), m.InBits)
+	lo = l
+	r := u.clone()
+	if l.Equals(u) && !m.Sticky {
+		r.Next()
+	}
+	for ; ratCmpBig(r, up) <= 0 && len(carries) < maxCarries; r.Next() {
+		num, den := r.Fraction()
+		carries = append(carries, Carry{
+			Exp:   e2,
+			K:     den,
+			Quo:   num,
+			Exact: ratCmpBig(r, exact) == 0,
+		})
+	}
+	return carries, lo, r
+}
+
+// ratCmpBig compares r and x.
+func ratCmpBig(r *Rat, x *big.Rat) int {
+	num, den := r.Fraction()
+	a := new(big.Int).Mul(new(big.Int).SetUint64(num), x.Denom())
+	b := new(big.Int).Mul(new(big.Int).SetUint64(den), x.Num())
+	return a.Cmp(b)
+}
+
+func pow5bits(e int) uint { return uint((e*1217359)>>19) + 1 }
+func log10Pow2(e int) int { return (e * 78913) >> 18 }
+func log10Pow5(e int) int { return (e * 732923) >> 20 }
+
+func pow2Big(e uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), e) }
+
+func pow5Big(e int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(5), big.NewInt(int64(e)), nil)
+}
+
+// ryu returns the MulShift for the reference implementation
+// of Ryū, using tables of powers of 5 with the given bit counts,
+// where q(e2) is the index of the table entry for exponent e2.
+// The table for negative exponents is
+//
+//	floor(5^i >> (bitlen(5^i) - pow5bits))
+//
+// and the table of inverses for positive exponents is
+//
+//	floor(2^(bitlen(5^q) - 1 + invbits) / 5^q) + 1.
+//
+// The inverse of 5^0 is 2^invbits+1, one bit wider than the others.
+func ryu(name string, mantbits uint, minExp, maxExp int, invbits, pow5bits_ uint, q func(e2 int) int) *MulShift {
+	tableBits := invbits + 1
+	if pow5bits_ > tableBits {
+		tableBits = pow5bits_
+	}
+	return &MulShift{
+		Name:      name,
+		InBits:    mantbits + 2,
+		TableBits: tableBits,
+		MinExp:    minExp,
+		MaxExp:    maxExp,
+		Shift: func(e2 int) uint {
+			q := q(e2)
+			if e2 >= 0 {
+				k := int(invbits+pow5bits(q)) - 1
+				return uint(-e2 + q + k)
+			}
+			i := -e2 - q
+			k := int(pow5bits(i)) - int(pow5bits_)
+			return uint(q - k)
+		},
+		Multiplier: func(e2 int) (*big.Int, *big.Rat) {
+			q := q(e2)
+			if e2 >= 0 {
+				// X = 2^(e2-q) / 5^q
+				p := pow5Big(q)
+				entry := pow2Big(uint(p.BitLen()) - 1 + invbits)
+				entry.Quo(entry, p)
+				entry.Add(entry, big.NewInt(1))
+				exact := new(big.Rat).SetFrac(pow2Big(uint(e2-q)), p)
+				return entry, exact
+			}
+			// X = 5^i / 2^q
+			i := -e2 - q
+			p := pow5Big(i)
+			entry := new(big.Int)
+			if l := uint(p.BitLen()); l >= pow5bits_ {
+				entry.Rsh(p, l-pow5bits_)
+			} else {
+				entry.Lsh(p, pow5bits_-l)
+			}
+			exact := new(big.Rat).SetFrac(p, pow2Big(uint(q)))
+			return entry, exact
+		},
+	}
+}
+
+// ryuQ returns the decimal exponent q chosen by Ryū for e2.
+// With qAdjust (as in d2s), it is decremented for large exponents
+// so that the last removed digit can be computed from the product.
+func ryuQ(qAdjust bool) func(e2 int) int {
+	return func(e2 int) int {
+		if e2 >= 0 {
+			q := log10Pow2(e2)
+			if qAdjust && e2 > 3 {
+				q--
+			}
+			return q
+		}
+		q := log10Pow5(-e2)
+		if qAdjust && -e2 > 1 {
+			q--
+		}
+		return q
+	}
+}
+
+// RyuFloat64 describes the multiplications of Ryū for float64
+// (125-bit tables, 55-bit inputs).
+func RyuFloat64() *MulShift {
+	return ryu("Ryū float64", 53, -1076, 969, 125, 125, ryuQ(true))
+}
+
+// RyuFloat32 describes the multiplications of Ryū for float32
+// (59-bit inverse table, 61-bit table, 26-bit inputs).
+// The products computing the last removed digit are described
+// by RyuFloat32LastDigit.
+func RyuFloat32() *MulShift {
+	return ryu("Ryū float32", 24, -151, 102, 59, 61, ryuQ(false))
+}
+
+// RyuFloat32LastDigit describes the additional multiplications of
+// Ryū for float32, which compute the last removed digit using the
+// table entries for q-1 (i+1 for negative exponents) when q > 0.
+// Only mv = 4×m2 is multiplied, so the inputs are the 24-bit
+// mantissas m2 and the multipliers are 4×X.
+func RyuFloat32LastDigit() *MulShift {
+	q := ryuQ(false)
+	m := ryu("Ryū float32 (last digit)", 24, -151, 102, 59, 61,
+		func(e2 int) int { return q(e2) - 1 })
+	shift, mult := m.Shift, m.Multiplier
+	m.InBits = 24
+	m.Shift = func(e2 int) uint { return shift(e2) - 2 }
+	m.Multiplier = func(e2 int) (*big.Int, *big.Rat) {
+		entry, exact := mult(e2)
+		return entry, exact.Mul(exact, big.NewRat(4, 1))
+	}
+	m.Skip = func(e2 int) bool { return q(e2) == 0 }
+	return m
+}
+
+// schubfach returns the MulShift for Schubfach-like algorithms,
+// where for exponent q (x = c × 2^q) the table entry is
+//
+//	g = floor(10^-k × 2^-r) + 1, with k = floor(q × log10(2))
+//
+// and r chosen such that g has exactly gbits bits. Products are
+// rounded to odd, so exact integers must be detected.
+func schubfach(name string, mantbits uint, minExp, maxExp int, gbits uint) *MulShift {
+	k := func(q int) int { return int(math.Floor(float64(q) * log2overlog10)) }
+	// floor(log2(10^-k))
+	flog2pow10 := func(k int) int { return int(math.Floor(-float64(k) / log2overlog10)) }
+	return &MulShift{
+		Name:      name,
+		InBits:    mantbits + 2,
+		TableBits: gbits,
+		MinExp:    minExp,
+		MaxExp:    maxExp,
+		Shift: func(q int) uint {
+			return uint(int(gbits) - 1 - flog2pow10(k(q)) - q)
+		},
+		Multiplier: func(q int) (*big.Int, *big.Rat) {
+			k := k(q)
+			// X = 2^q × 10^-k
+			exact := new(big.Rat).SetInt64(1)
+			p10 := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(k))), nil)
+			if k >= 0 {
+				exact.SetFrac(big.NewInt(1), p10)
+			} else {
+				exact.SetInt(p10)
+			}
+			if q >= 0 {
+				exact.Mul(exact, new(big.Rat).SetInt(pow2Big(uint(q))))
+			} else {
+				exact.Quo(exact, new(big.Rat).SetInt(pow2Big(uint(-q))))
+			}
+			// g = floor(10^-k × 2^(gbits-1-flog2pow10(k))) + 1
+			s := int(gbits) - 1 - flog2pow10(k)
+			num, den := big.NewInt(1), big.NewInt(1)
+			if k >= 0 {
+				den = p10
+			} else {
+				num = p10
+			}
+			if s >= 0 {
+				num = new(big.Int).Lsh(num, uint(s))
+			} else {
+				den = new(big.Int).Lsh(den, uint(-s))
+			}
+			g := new(big.Int).Quo(num, den)
+			g.Add(g, big.NewInt(1))
+			return g, exact
+		},
+		Sticky: true,
+	}
+}
+
+// SchubfachFloat64 describes the multiplications of Schubfach
+// for float64 (126-bit table, 55-bit inputs).
+func SchubfachFloat64() *MulShift {
+	return schubfach("Schubfach float64", 53, -1074, 971, 126)
+}
+
+// SchubfachFloat32 describes the multiplications of Schubfach
+// for float32 (63-bit table, 26-bit inputs).
+func SchubfachFloat32() *MulShift {
+	return schubfach("Schubfach float32", 24, -149, 104, 63)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
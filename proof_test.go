@@ -0,0 +1,77 @@
+package fptest
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestProveRyu(t *testing.T) {
+	for _, m := range []*MulShift{RyuFloat32(), RyuFloat32LastDigit(), RyuFloat64()} {
+		var buf bytes.Buffer
+		carries := m.Prove(&buf)
+		for _, c := range carries {
+			t.Errorf("%s: %s", m.Name, c)
+		}
+		t.Logf("%s: %d lines of certificate", m.Name, bytes.Count(buf.Bytes(), []byte("\n")))
+	}
+}
+
+func TestProveSchubfach(t *testing.T) {
+	// Truncated products never cross an integer, but for small
+	// exponents the exact product k × X can be an integer
+	// while the approximate product is not.
+	for _, m := range []*MulShift{SchubfachFloat32(), SchubfachFloat64()} {
+		carries := m.Prove(nil)
+		exact := 0
+		for _, c := range carries {
+			if !c.Exact {
+				t.Errorf("%s: %s", m.Name, c)
+			} else {
+				exact++
+			}
+		}
+		t.Logf("%s: %d exact products", m.Name, exact)
+	}
+}
+
+func TestProveCarry(t *testing.T) {
+	// A 64-bit table is not enough for float64.
+	m := RyuFloat64()
+	m.Name = "Ryū float64 (truncated)"
+	shift, mult := m.Shift, m.Multiplier
+	m.TableBits = 64
+	m.Shift = func(e2 int) uint { return shift(e2) - 61 }
+	m.Multiplier = func(e2 int) (entry *big.Int, exact *big.Rat) {
+		entry, exact = mult(e2)
+		entry.Rsh(entry, 61)
+		return entry, exact
+	}
+	m.MinExp, m.MaxExp = 900, 969
+	carries := m.Prove(nil)
+	if len(carries) == 0 {
+		t.Errorf("expected counterexamples with 64-bit table")
+	}
+	for i, c := range carries {
+		if i < 5 {
+			t.Log(c)
+		}
+	}
+}
+
+func TestProveTableBits(t *testing.T) {
+	// Table entries wider than TableBits are rejected.
+	for _, m := range []*MulShift{RyuFloat32(), RyuFloat64()} {
+		m.TableBits--
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s: no panic with %d-bit table", m.Name, m.TableBits)
+				} else {
+					t.Log(r)
+				}
+			}()
+			m.Prove(nil)
+		}()
+	}
+}
@@ -0,0 +1,107 @@
+package fptest
+
+import (
+	"math/big"
+)
+
+// ForRounding returns the mode of the hard cases of m in the rounding
+// mode rm. In nearest modes, the rounding boundaries are the midpoints
+// (for parsing) and half-decimals (for formatting), and m is unchanged:
+// exact ties, where ToNearestEven and ToNearestAway differ, are not
+// enumerated. In directed modes, the rounding boundaries are the floats
+// and decimals themselves, and the hard cases are NearFloats.
+func (m Mode) ForRounding(rm big.RoundingMode) Mode {
+	switch rm {
+	case big.ToNearestEven, big.ToNearestAway:
+		return m
+	}
+	return NearFloats
+}
+
+// A Rounded is a hard case with its expected results
+// in a rounding mode.
+type Rounded struct {
+	HardCase
+	Mode big.RoundingMode
+	// Float is the decimal number of the hard case rounded
+	// to the format: the expected result of parsing.
+	Float float64
+	// Decimal is X rounded to a multiple of 10^K, divided by 10^K:
+	// the expected digits when formatting X with exponent K.
+	Decimal uint64
+}
+
+// BestFirstRounding is like BestFirst for the rounding mode rm.
+// The mode m (Midpoints for parsing, HalfDecimals for formatting)
+// selects the hard cases of m.ForRounding(rm), which are returned
+// with their expected results.
+func BestFirstRounding(f Format, m Mode, rm big.RoundingMode, digits int, precision uint, count int,
+	fn func(c Rounded)) {
+	m = m.ForRounding(rm)
+	BestFirst(f, m, digits, precision, count, func(c HardCase) {
+		fn(f.Rounded(c, m, rm))
+	})
+}
+
+// AlmostRoundingBoundary is like AlmostDecimalMidpoint (for m =
+// Midpoints) or AlmostHalfDecimal (for m = HalfDecimals) in the
+// rounding mode rm, for format f: it enumerates the hard cases of
+// m.ForRounding(rm), using AlmostDecimal in directed modes, and
+// returns them with their expected results (see Format.Rounded).
+func AlmostRoundingBoundary(f Format, m Mode, rm big.RoundingMode, e2 int, digits int, precision uint,
+	direction int, denormal bool, fn func(c Rounded)) {
+	m = m.ForRounding(rm)
+	t := f.target(m, e2, digits, denormal)
+	if t == nil {
+		return
+	}
+	// As in AlmostDecimalMidpoint, cases below the boundary
+	// are given by fractions above the target.
+	t.walk(precision, -direction, func(x float64, n uint64, k int) {
+		c := HardCase{X: x, Exp: e2, N: n, K: k}
+		c.Eps = roundingEps(m, c)
+		fn(f.Rounded(c, m, rm))
+	})
+}
+
+// roundingEps returns the relative difference between the decimal
+// of c and the rounding boundary of mode m (the midpoint above X
+// for Midpoints, X otherwise).
+func roundingEps(m Mode, c HardCase) float64 {
+	d := decimalRat(c.N, c.K)
+	if m == HalfDecimals {
+		d.Add(d, decimalRat(5, c.K-1))
+	}
+	b := new(big.Rat).SetFloat64(c.X)
+	if m == Midpoints {
+		// Half an ulp, which may not be a float64.
+		half := new(big.Rat)
+		if c.Exp >= 1 {
+			half.SetInt(pow2Big(uint(c.Exp - 1)))
+		} else {
+			half.SetFrac(big.NewInt(1), pow2Big(uint(1-c.Exp)))
+		}
+		b.Add(b, half)
+	}
+	d.Quo(d, b)
+	eps, _ := d.Sub(d, big.NewRat(1, 1)).Float64()
+	return eps
+}
+
+// Rounded returns the expected results of the hard case c
+// of mode m in the rounding mode rm, computed exactly.
+func (f Format) Rounded(c HardCase, m Mode, rm big.RoundingMode) Rounded {
+	d := decimalRat(c.N, c.K)
+	if m == HalfDecimals {
+		// (N+1/2)×10^K
+		d.Add(d, decimalRat(5, c.K-1))
+	}
+	x := new(big.Rat).SetFloat64(c.X)
+	x.Quo(x, decimalRat(1, c.K))
+	return Rounded{
+		HardCase: c,
+		Mode:     rm,
+		Float:    f.roundMode(d, rm),
+		Decimal:  roundInt(x.Num(), x.Denom(), rm).Uint64(),
+	}
+}
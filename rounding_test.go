@@ -0,0 +1,131 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+func TestFormatRoundMode(t *testing.T) {
+	// Rounding of numbers between two float32 in all modes.
+	x := float64(float32(1.1))
+	next := float64(math.Nextafter32(float32(x), 2))
+	for _, c := range []struct {
+		r    *big.Rat
+		want [6]float64 // in the order of bigModes
+	}{
+		{new(big.Rat).SetFloat64(x), [6]float64{x, x, x, x, x, x}},
+		{new(big.Rat).SetFloat64(x + (next-x)/4), [6]float64{x, x, x, next, x, next}},
+		{new(big.Rat).SetFloat64(x + (next-x)/2), [6]float64{next, next, x, next, x, next}},
+		{new(big.Rat).SetFloat64(x + 3*(next-x)/4), [6]float64{next, next, x, next, x, next}},
+		// Above the largest float32.
+		{new(big.Rat).SetInt(pow2Big(200)),
+			[6]float64{math.Inf(1), math.Inf(1), math.MaxFloat32, math.Inf(1), math.MaxFloat32, math.Inf(1)}},
+	} {
+		for i, mode := range bigModes {
+			if y := Float32.roundMode(c.r, mode); y != c.want[i] {
+				t.Errorf("round(%s, %s) = %b, want %b", c.r.FloatString(10), mode, y, c.want[i])
+			}
+		}
+	}
+}
+
+func TestBestFirstRounding(t *testing.T) {
+	const digits = 17
+	const prec = 90
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		for _, rm := range bigModes {
+			n := 0
+			BestFirstRounding(Float64, m, rm, digits, prec, 200, func(c Rounded) {
+				n++
+				d := decimalRat(c.N, c.K)
+				if m.ForRounding(rm) == HalfDecimals {
+					d.Add(d, decimalRat(5, c.K-1))
+				}
+				// The expected float, using big.Float which has no
+				// denormals but rounds correctly.
+				if c.X >= math.Ldexp(1, -1022) {
+					want, _ := new(big.Float).SetPrec(53).SetMode(rm).SetRat(d).Float64()
+					if c.Float != want {
+						t.Errorf("%s %s: %+v, want Float=%b", m, rm, c, want)
+					}
+				}
+				// The decimal is the nearest multiple of 10^K in the
+				// right direction.
+				x := new(big.Rat).SetFloat64(c.X)
+				diff := x.Sub(x, decimalRat(c.Decimal, c.K))
+				unit := decimalRat(1, c.K)
+				var ok bool
+				switch rm {
+				case big.ToZero, big.ToNegativeInf:
+					ok = diff.Sign() >= 0 && diff.Cmp(unit) < 0
+				case big.AwayFromZero, big.ToPositiveInf:
+					ok = diff.Sign() <= 0 && diff.Neg(diff).Cmp(unit) < 0
+				default:
+					ok = diff.Abs(diff).Cmp(decimalRat(5, c.K-1)) < 0
+				}
+				if !ok {
+					t.Errorf("%s %s: %+v: wrong Decimal", m, rm, c)
+				}
+				// In directed modes, the hard cases are near floats.
+				if m.ForRounding(rm) == NearFloats && c.Float != c.X &&
+					c.Float != math.Nextafter(c.X, 0) && c.Float != math.Nextafter(c.X, math.Inf(1)) {
+					t.Errorf("%s %s: %+v: Float is not a neighbour of X", m, rm, c)
+				}
+			})
+			if n != 200 {
+				t.Errorf("%s %s: got %d cases", m, rm, n)
+			}
+		}
+	}
+}
+
+func TestAlmostRoundingBoundary(t *testing.T) {
+	// The decimals are close to the rounding boundaries:
+	// midpoints or half-decimals in nearest modes,
+	// floats in directed modes.
+	const digits = 9
+	const prec = 50
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		for _, rm := range bigModes {
+			bound := m.ForRounding(rm)
+			count := 0
+			for e2 := -100; e2 <= 100; e2 += 7 {
+				for _, dir := range []int{-1, +1} {
+					// The range may end with a fraction (and its
+					// multiples) beyond the precision.
+					end := false
+					AlmostRoundingBoundary(Float32, m, rm, e2, digits, prec, dir, false, func(c Rounded) {
+						x, n, k := c.X, c.N, c.K
+						eps := caseEps(Float32, bound, x, n, k)
+						if eps*float64(dir) > 0 || end && math.Abs(eps) < math.Ldexp(1, -prec) {
+							t.Errorf("%s %s: %v, %de%d: eps=%g", m, rm, x, n, k, eps)
+						}
+						if c.Eps != eps || c.Exp != e2 {
+							t.Errorf("%s %s: %+v, want eps=%g", m, rm, c.HardCase, eps)
+						}
+						// The expected result of parsing, by big.Float.
+						s := strconv.FormatUint(n, 10) + "e" + strconv.Itoa(k)
+						if bound == HalfDecimals {
+							s = strconv.FormatUint(n, 10) + "5e" + strconv.Itoa(k-1)
+						}
+						z, _, _ := new(big.Float).SetPrec(24).SetMode(rm).Parse(s, 10)
+						if want, _ := z.Float32(); c.Float != float64(want) {
+							t.Errorf("%s %s: %s rounds to %v, want %v", m, rm, s, c.Float, want)
+						}
+						if math.Abs(eps) >= math.Ldexp(1, -prec) {
+							end = true
+							return
+						}
+						count++
+					})
+				}
+			}
+			if count == 0 {
+				t.Errorf("%s %s: no cases", m, rm)
+			}
+			t.Logf("%s %s: %d cases", m, rm, count)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package fptest
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// SampleDecimalMidpoint is like AlmostDecimalMidpoint, but only returns
+// count cases drawn uniformly at random, without replacement,
+// or all cases if there are not more than count. Cases are returned
+// by increasing value of n/(2*mant+1).
+//
+// The cases are found by descending the Stern-Brocot tree, weighted
+// by the number of cases below each node (see CountRange), so that the
+// cost does not depend on the size of the range.
+func SampleDecimalMidpoint(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	rnd *rand.Rand, count int, f func(x float64, n uint64, k int)) {
+	if t := midpointTarget(e2, digits, mantbits, denormal); t != nil {
+		t.sample(precision, -direction, rnd, count, f)
+	}
+}
+
+// SampleHalfDecimal is like AlmostHalfDecimal, but only returns
+// count cases drawn uniformly at random (see SampleDecimalMidpoint).
+func SampleHalfDecimal(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	rnd *rand.Rand, count int, f func(x float64, n uint64, k int)) {
+	if t := halfDecimalTarget(e2, digits, mantbits, denormal); t != nil {
+		t.sample(precision, -direction, rnd, count, f)
+	}
+}
+
+func (t *target) sample(precision uint, direction int, rnd *rand.Rand, count int,
+	f func(x float64, n uint64, k int)) {
+	r1, r2 := ratRange(t.num, t.den, precision, direction, t.nbits)
+	c := newCounter(r1, r2, t.nbits, t.denormal, t.parity)
+	var idx []uint64
+	if c.total <= uint64(count) {
+		idx = make([]uint64, c.total)
+		for i := range idx {
+			idx[i] = uint64(i)
+		}
+	} else {
+		// Draw distinct indices.
+		seen := make(map[uint64]bool, count)
+		idx = make([]uint64, 0, count)
+		for len(idx) < count {
+			i := randUint64n(rnd, c.total)
+			if !seen[i] {
+				seen[i] = true
+				idx = append(idx, i)
+			}
+		}
+		sort.Slice(idx, func(i, j int) bool { return idx[i] < idx[j] })
+	}
+	for _, i := range idx {
+		a, b := c.ith(i)
+		t.emit(a, b, f)
+	}
+}
+
+// randUint64n returns a uniform random integer in [0, n).
+func randUint64n(rnd *rand.Rand, n uint64) uint64 {
+	// Reject values in the incomplete last block.
+	max := ^uint64(0) - (^uint64(0)%n+1)%n
+	for {
+		if v := rnd.Uint64(); v <= max {
+			return v % n
+		}
+	}
+}
+
+// multiples returns the multiples j×(a, b) of the irreducible
+// fraction a/b which are counted: j = j0 + i×step for 0 <= i < n.
+func (c *counter) multiples(a, b uint64) (j0, step, n uint64) {
+	step = 1
+	switch {
+	case a == 0,
+		c.parity == OddNumerator && a%2 == 0,
+		c.parity == OddDenominator && b%2 == 0:
+		return 0, 1, 0
+	case c.parity != AnyParity:
+		// Only odd multiples.
+		step = 2
+	}
+	j0 = (c.bmin + b - 1) / b
+	jmax := c.bmax / b
+	if step == 2 && j0%2 == 0 {
+		j0++
+	}
+	if j0 > jmax {
+		return j0, step, 0
+	}
+	return j0, step, (jmax-j0)/step + 1
+}
+
+// ith returns the i-th fraction in [r1, r2), in increasing order
+// (multiples of the same fraction are ordered by increasing size).
+func (c *counter) ith(i uint64) (a, b uint64) {
+	// The fraction is a node of the Stern-Brocot tree,
+	// below the mediant of lp/lq and rp/rq.
+	var lp, lq, rp, rq uint64 = 0, 1, 1, 0
+	// before returns whether p/q and its multiples come before index i.
+	before := func(p, q uint64) bool {
+		if fracLess(p, q, c.r1.a, c.r1.c) {
+			return true
+		}
+		_, _, n := c.multiples(p, q)
+		return c.below(p, q)+n <= i
+	}
+	// after returns whether p/q comes after index i.
+	after := func(p, q uint64) bool {
+		return c.below(p, q) > i
+	}
+	for {
+		mp, mq := lp+rp, lq+rq
+		switch {
+		case before(mp, mq):
+			// Go right: find the largest k such that L+kR is before i.
+			k := gallop(maxMul(lp, lq, rp, rq), func(k uint64) bool {
+				return before(lp+k*rp, lq+k*rq)
+			})
+			lp, lq = lp+k*rp, lq+k*rq
+		case after(mp, mq):
+			// Go left: find the largest k such that kL+R is after i.
+			k := gallop(maxMul(rp, rq, lp, lq), func(k uint64) bool {
+				return after(k*lp+rp, k*lq+rq)
+			})
+			rp, rq = k*lp+rp, k*lq+rq
+		default:
+			j0, step, _ := c.multiples(mp, mq)
+			j := j0 + (i-c.below(mp, mq))*step
+			return j * mp, j * mq
+		}
+	}
+}
+
+// maxMul returns the largest k such that x+k×y does not
+// overflow, for both (x, y) = (p, r) and (q, s).
+func maxMul(p, q, r, s uint64) uint64 {
+	k := ^uint64(0)
+	if r > 0 {
+		k = (^uint64(0) - p) / r
+	}
+	if s > 0 && (^uint64(0)-q)/s < k {
+		k = (^uint64(0) - q) / s
+	}
+	return k
+}
+
+// gallop returns the largest k in [1, kmax] such that pred(k) holds,
+// assuming that pred(1) holds and pred is decreasing.
+func gallop(kmax uint64, pred func(k uint64) bool) uint64 {
+	if kmax == ^uint64(0) {
+		kmax--
+	}
+	lo, hi := uint64(1), uint64(2)
+	for hi <= kmax && pred(hi) {
+		lo = hi
+		if hi > kmax/2 {
+			hi = kmax + 1
+			break
+		}
+		hi *= 2
+	}
+	if hi > kmax {
+		hi = kmax + 1
+	}
+	// pred(lo) holds, pred(hi) does not (or hi > kmax).
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if pred(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
@@ -0,0 +1,121 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestCounterIth(t *testing.T) {
+	// The i-th fraction is the i-th element of the Farey walk.
+	for _, tc := range []struct {
+		t         *target
+		precision uint
+	}{
+		{midpointTarget(200, 8, 24, false), 38},
+		{midpointTarget(-140, 8, 23, true), 40},
+		{halfDecimalTarget(-60, 6, 24, false), 40},
+		{halfDecimalTarget(300, 15, 53, false), 95},
+	} {
+		for _, dir := range []int{-1, +1} {
+			r1, r2 := ratRange(tc.t.num, tc.t.den, tc.precision, dir, tc.t.nbits)
+			c := newCounter(r1.clone(), r2, tc.t.nbits, tc.t.denormal, tc.t.parity)
+			var i uint64
+			for r := r1; r.Less(r2); r.Next() {
+				a, b := r.Fraction()
+				j0, step, n := c.multiples(a, b)
+				for j := j0; n > 0; j, n = j+step, n-1 {
+					ga, gb := c.ith(i)
+					if ga != j*a || gb != j*b {
+						t.Fatalf("fraction %d is %d/%d, expected %d/%d", i, ga, gb, j*a, j*b)
+					}
+					i++
+				}
+			}
+			if i != c.total {
+				t.Errorf("walked %d fractions, counted %d", i, c.total)
+			}
+			t.Logf("dir=%d: %d fractions", dir, i)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	// Sampling is reproducible and returns valid cases.
+	const count = 100
+	var first []float64
+	for iter := 0; iter < 2; iter++ {
+		rnd := rand.New(rand.NewSource(1))
+		var got []float64
+		seen := make(map[float64]bool)
+		SampleDecimalMidpoint(600, 17, 53, 60, +1, false, rnd, count, func(x float64, n uint64, k int) {
+			got = append(got, x)
+			if seen[x] {
+				t.Errorf("duplicate case %v", x)
+			}
+			seen[x] = true
+			eps := caseEps(Float64, Midpoints, x, n, k)
+			if eps >= 0 || eps < -math.Ldexp(1, -60) {
+				t.Errorf("invalid case %v %de%d: eps=%g", x, n, k, eps)
+			}
+		})
+		if len(got) != count {
+			t.Errorf("got %d cases", len(got))
+		}
+		if iter == 1 {
+			for i := range got {
+				if got[i] != first[i] {
+					t.Errorf("sampling is not reproducible")
+					break
+				}
+			}
+		}
+		first = got
+	}
+
+	// Small ranges return all cases, in the same order.
+	var last *big.Rat
+	lastX, n := 0.0, 0
+	SampleHalfDecimal(100, 12, 53, 78, +1, false, rand.New(rand.NewSource(1)), 2000,
+		func(x float64, m uint64, k int) {
+			n++
+			// (2m+1) / mant
+			v := new(big.Rat).SetFrac(new(big.Int).SetUint64(2*m+1), big.NewInt(int64(math.Ldexp(x, -100))))
+			if last != nil {
+				if c := v.Cmp(last); c < 0 || c == 0 && x <= lastX {
+					t.Errorf("%v %de%d is out of order", x, m, k)
+				}
+			}
+			last, lastX = v, x
+		})
+	if want := CountHalfDecimal(100, 12, 53, 78, +1, false); uint64(n) != want {
+		t.Errorf("got %d cases, expected %d", n, want)
+	}
+}
+
+func TestSampleUniform(t *testing.T) {
+	// Sample a range of about 3000 cases and check
+	// that the number of cases in each tenth is balanced.
+	tg := midpointTarget(80, 10, 53, false)
+	r1, r2 := ratRange(tg.num, tg.den, 70, +1, tg.nbits)
+	c := newCounter(r1, r2, tg.nbits, false, tg.parity)
+	t.Logf("%d cases", c.total)
+	var hist [10]int
+	rnd := rand.New(rand.NewSource(3))
+	lo := new(big.Rat).SetFrac(tg.num, tg.den)
+	width := new(big.Rat).Sub(r2.BigRat(), lo)
+	for i := 0; i < 5000; i++ {
+		a, b := c.ith(randUint64n(rnd, c.total))
+		v := new(big.Rat).SetFrac(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+		v.Sub(v, lo).Quo(v, width)
+		f, _ := v.Float64()
+		hist[int(f*10)]++
+	}
+	for _, h := range hist {
+		if h < 400 || h > 600 {
+			t.Errorf("unbalanced histogram %v", hist)
+			break
+		}
+	}
+}
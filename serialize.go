@@ -0,0 +1,185 @@
+package fptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatJSON returns the encoding of the shortest decimal
+// representation n×10^k of a float by encoding/json: like ECMAScript,
+// it uses the %f style between 1e-6 and 1e21, and the %e style
+// otherwise, without padding the exponent to two digits.
+func FormatJSON(n uint64, k int) string {
+	digits, dp := shortestDigits(n, k)
+	if exp := dp - 1; exp < -6 || exp >= 21 {
+		return formatE(digits, exp, 1)
+	}
+	return formatF(digits, dp)
+}
+
+// FormatFmt returns the output of fmt.Sprint (the %v verb) for a
+// float with shortest decimal representation n×10^k: it uses
+// the %e style when the decimal exponent is less than -4 or at least
+// 6, as strconv.FormatFloat(x, 'g', -1, bits).
+func FormatFmt(n uint64, k int) string {
+	digits, dp := shortestDigits(n, k)
+	if exp := dp - 1; exp < -4 || exp >= 6 {
+		return formatE(digits, exp, 2)
+	}
+	return formatF(digits, dp)
+}
+
+// FormatFixedE returns the output of %.*e for a float
+// rounded to n×10^k, where n has exactly digits digits.
+// It returns false if n does not have that number of digits.
+func FormatFixedE(n uint64, k int, digits int) (string, bool) {
+	s := strconv.FormatUint(n, 10)
+	if len(s) != digits {
+		return "", false
+	}
+	return formatE(s, k+digits-1, 2), true
+}
+
+// shortestDigits returns the digits of n without trailing zeros,
+// and the position of the decimal point dp, such that
+// n×10^k = 0.digits × 10^dp.
+func shortestDigits(n uint64, k int) (digits string, dp int) {
+	if n == 0 {
+		return "0", 1
+	}
+	for n%10 == 0 {
+		n /= 10
+		k++
+	}
+	digits = strconv.FormatUint(n, 10)
+	return digits, len(digits) + k
+}
+
+// formatE formats 0.digits × 10^(exp+1) as d.ddde±XX with at least
+// expDigits digits in the exponent.
+func formatE(digits string, exp int, expDigits int) string {
+	var b strings.Builder
+	b.WriteByte(digits[0])
+	if len(digits) > 1 {
+		b.WriteByte('.')
+		b.WriteString(digits[1:])
+	}
+	b.WriteByte('e')
+	if exp < 0 {
+		b.WriteByte('-')
+		exp = -exp
+	} else {
+		b.WriteByte('+')
+	}
+	e := strconv.Itoa(exp)
+	for i := len(e); i < expDigits; i++ {
+		b.WriteByte('0')
+	}
+	b.WriteString(e)
+	return b.String()
+}
+
+// formatF formats 0.digits × 10^dp without exponent.
+func formatF(digits string, dp int) string {
+	switch {
+	case dp <= 0:
+		return "0." + strings.Repeat("0", -dp) + digits
+	case dp >= len(digits):
+		return digits + strings.Repeat("0", dp-len(digits))
+	}
+	return digits[:dp] + "." + digits[dp:]
+}
+
+// CheckJSON checks that a struct field holding the float x (a float32
+// if bits is 32), with shortest decimal representation n×10^k,
+// is encoded by encoding/json as FormatJSON(n, k), and that
+// decoding the output gives back x.
+func CheckJSON(x float64, bits int, n uint64, k int) error {
+	want := `{"X":` + FormatJSON(n, k) + `}`
+	var out []byte
+	var err error
+	if bits == 32 {
+		out, err = json.Marshal(struct{ X float32 }{float32(x)})
+	} else {
+		out, err = json.Marshal(struct{ X float64 }{x})
+	}
+	if err != nil {
+		return err
+	}
+	if string(out) != want {
+		return fmt.Errorf("json.Marshal(%v) = %s, want %s", x, out, want)
+	}
+	var y float64
+	if bits == 32 {
+		var v struct{ X float32 }
+		err = json.Unmarshal(out, &v)
+		y = float64(v.X)
+	} else {
+		var v struct{ X float64 }
+		err = json.Unmarshal(out, &v)
+		y = v.X
+	}
+	if err != nil {
+		return fmt.Errorf("json.Unmarshal(%s): %s", out, err)
+	}
+	if math.Float64bits(y) != math.Float64bits(x) {
+		return fmt.Errorf("json.Unmarshal(%s) = %v, want %v", out, y, x)
+	}
+	return nil
+}
+
+// CheckFmt checks that the float x (a float32 if bits is 32),
+// with shortest decimal representation n×10^k, is formatted by
+// fmt.Sprint as FormatFmt(n, k), and that fmt.Sscan parses
+// the output back to x.
+func CheckFmt(x float64, bits int, n uint64, k int) error {
+	want := FormatFmt(n, k)
+	var s string
+	if bits == 32 {
+		s = fmt.Sprint(float32(x))
+	} else {
+		s = fmt.Sprint(x)
+	}
+	if s != want {
+		return fmt.Errorf("fmt.Sprint(%v) = %s, want %s", x, s, want)
+	}
+	var y float64
+	var err error
+	if bits == 32 {
+		var v float32
+		_, err = fmt.Sscan(s, &v)
+		y = float64(v)
+	} else {
+		_, err = fmt.Sscan(s, &y)
+	}
+	if err != nil {
+		return fmt.Errorf("fmt.Sscan(%q): %s", s, err)
+	}
+	if math.Float64bits(y) != math.Float64bits(x) {
+		return fmt.Errorf("fmt.Sscan(%q) = %v, want %v", s, y, x)
+	}
+	return nil
+}
+
+// CheckFmtFixed checks that fmt.Sprintf("%.*e") formats the float x
+// (a float32 if bits is 32) with the given number of digits as
+// n×10^k, which must have exactly that many digits.
+func CheckFmtFixed(x float64, bits int, n uint64, k int, digits int) error {
+	want, ok := FormatFixedE(n, k, digits)
+	if !ok {
+		return fmt.Errorf("%d does not have %d digits", n, digits)
+	}
+	var s string
+	if bits == 32 {
+		s = fmt.Sprintf("%.*e", digits-1, float32(x))
+	} else {
+		s = fmt.Sprintf("%.*e", digits-1, x)
+	}
+	if s != want {
+		return fmt.Errorf("fmt.Sprintf(%%.%de, %v) = %s, want %s", digits-1, x, s, want)
+	}
+	return nil
+}
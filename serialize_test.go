@@ -0,0 +1,83 @@
+package fptest
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// shortestDecimal returns the shortest decimal representation
+// n×10^k of x (a float32 if bits is 32), computed exactly.
+func shortestDecimal(x float64, bits int) (n uint64, k int) {
+	f := Float64
+	if bits == 32 {
+		f = Float32
+	}
+	digits, e := exactShortest(f, x)
+	n, _ = strconv.ParseUint(digits, 10, 64)
+	return n, e - len(digits)
+}
+
+// fixedDecimal returns the positive x rounded to the given number
+// of significant digits (ties to even) as n×10^k, computed exactly.
+func fixedDecimal(x float64, digits int) (n uint64, k int) {
+	r := new(big.Rat).SetFloat64(x)
+	k = decimalExp(r) - digits
+	r.Quo(r, pow10Rat(k))
+	n = roundInt(r.Num(), r.Denom(), big.ToNearestEven).Uint64()
+	if len(strconv.FormatUint(n, 10)) > digits {
+		// Rounded up to 10^digits.
+		n, k = n/10, k+1
+	}
+	return n, k
+}
+
+func TestFormatJSONFmt(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		x := math.Float64frombits(rnd.Uint64() &^ (1 << 63))
+		bits := 64
+		switch {
+		case i%2 == 1:
+			x = float64(math.Float32frombits(rnd.Uint32() &^ (1 << 31)))
+			bits = 32
+		case i%4 == 2:
+			// Around the cutoffs.
+			x = math.Ldexp(float64(rnd.Int63()), -63-rnd.Intn(30)) * math.Pow(10, float64(rnd.Intn(30)))
+		}
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			continue
+		}
+		n, k := shortestDecimal(x, bits)
+		var js []byte
+		if bits == 32 {
+			js, _ = json.Marshal(float32(x))
+		} else {
+			js, _ = json.Marshal(x)
+		}
+		if got := FormatJSON(n, k); got != string(js) {
+			t.Errorf("FormatJSON(%d, %d) = %s, want %s", n, k, got, js)
+		}
+		want := strconv.FormatFloat(x, 'g', -1, bits)
+		if got := FormatFmt(n, k); got != want {
+			t.Errorf("FormatFmt(%d, %d) = %s, want %s", n, k, got, want)
+		}
+		if err := CheckJSON(x, bits, n, k); err != nil {
+			t.Error(err)
+		}
+		if err := CheckFmt(x, bits, n, k); err != nil {
+			t.Error(err)
+		}
+		digits := 1 + rnd.Intn(15)
+		if bits == 32 {
+			digits = 1 + rnd.Intn(8)
+		}
+		n, k = fixedDecimal(x, digits)
+		if err := CheckFmtFixed(x, bits, n, k, digits); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package fptest
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A Spelling is a set of syntactic variants of a decimal number,
+// for the grammar of parsers. Spellings are combined as bit flags.
+type Spelling uint
+
+const (
+	// Canonical is "NeK", as used by the torture tests.
+	Canonical Spelling = 1 << iota
+	// FixedPoint has no exponent: "0.000123" or "123000.0".
+	FixedPoint
+	// Scientific has a single digit before the decimal point: "1.23e-4".
+	Scientific
+	// LeadingZeros prepends zeros to N: "000123e-6".
+	LeadingZeros
+	// TrailingZeros appends zeros to N: "123000e-9".
+	TrailingZeros
+	// PlusSign uses explicit signs: "+123e+6".
+	PlusSign
+	// UpperE uses an uppercase exponent: "123E-6".
+	UpperE
+	// ExpPadding pads the exponent with zeros: "123e-0006".
+	ExpPadding
+	// Underscores separates groups of digits, as allowed
+	// by Go syntax: "123_456e-6".
+	Underscores
+	// Hex is the hexadecimal form "0x1e240p0" of integers
+	// (K >= 0), with Go syntax.
+	Hex
+
+	// StandardSpellings are accepted by most parsers (strtod, Python).
+	StandardSpellings = Canonical | FixedPoint | Scientific | LeadingZeros |
+		TrailingZeros | PlusSign | UpperE | ExpPadding
+	// AllSpellings includes the Go-specific spellings.
+	AllSpellings = StandardSpellings | Underscores | Hex
+)
+
+// Spell returns the spellings of n×10^k in the set s, in the order
+// of the flags. Spellings which do not apply (Hex for K < 0)
+// are omitted. They all have the same exact value, so they must
+// be parsed to the same float.
+func Spell(n uint64, k int, s Spelling) []string {
+	N := strconv.FormatUint(n, 10)
+	K := strconv.Itoa(k)
+	signedK := K // with an explicit sign
+	if k >= 0 {
+		signedK = "+" + K
+	}
+	var out []string
+	for flag := Canonical; flag <= Hex; flag <<= 1 {
+		if s&flag == 0 {
+			continue
+		}
+		switch flag {
+		case Canonical:
+			out = append(out, N+"e"+K)
+		case FixedPoint:
+			str := formatF(shortestDigits(n, k))
+			if !strings.Contains(str, ".") {
+				str += ".0"
+			}
+			out = append(out, str)
+		case Scientific:
+			digits, dp := shortestDigits(n, k)
+			out = append(out, formatE(digits, dp-1, 1))
+		case LeadingZeros:
+			out = append(out, "000"+N+"e"+K)
+		case TrailingZeros:
+			out = append(out, N+"000e"+strconv.Itoa(k-3))
+		case PlusSign:
+			out = append(out, "+"+N+"e"+signedK)
+		case UpperE:
+			out = append(out, N+"E"+K)
+		case ExpPadding:
+			e := strconv.Itoa(abs(k))
+			for len(e) < 4 {
+				e = "0" + e
+			}
+			out = append(out, N+"e"+signedK[:1]+e)
+		case Underscores:
+			out = append(out, groupDigits(N)+"e"+K)
+		case Hex:
+			if k >= 0 {
+				v := new(big.Int).Mul(new(big.Int).SetUint64(n), pow10Big(k))
+				out = append(out, "0x"+v.Text(16)+"p0")
+			}
+		}
+	}
+	return out
+}
+
+// groupDigits separates groups of 3 digits by underscores.
+func groupDigits(s string) string {
+	var b strings.Builder
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte('_')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
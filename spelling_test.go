@@ -0,0 +1,45 @@
+package fptest
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSpell(t *testing.T) {
+	for _, c := range []struct {
+		n    uint64
+		k    int
+		want []string
+	}{
+		{123, -6, []string{"123e-6", "0.000123", "1.23e-4", "000123e-6", "123000e-9",
+			"+123e-6", "123E-6", "123e-0006", "123e-6"}},
+		{1234567, 3, []string{"1234567e3", "1234567000.0", "1.234567e+9", "0001234567e3", "1234567000e0",
+			"+1234567e+3", "1234567E3", "1234567e+0003", "1_234_567e3", "0x4995ff58p0"}},
+		{1200, -2, []string{"1200e-2", "12.0", "1.2e+1", "0001200e-2", "1200000e-5",
+			"+1200e-2", "1200E-2", "1200e-0002", "1_200e-2"}},
+	} {
+		if got := Spell(c.n, c.k, AllSpellings); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Spell(%d, %d) = %q, want %q", c.n, c.k, got, c.want)
+		}
+	}
+	if got := Spell(5, -1, FixedPoint|UpperE); !reflect.DeepEqual(got, []string{"0.5", "5E-1"}) {
+		t.Errorf("Spell(5, -1, FixedPoint|UpperE) = %q", got)
+	}
+}
+
+func TestSpellValues(t *testing.T) {
+	// All spellings have the same exact value.
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		n := rnd.Uint64() >> uint(rnd.Intn(64))
+		k := rnd.Intn(80) - 40
+		want := decimalRat(n, k)
+		for _, s := range Spell(n, k, AllSpellings) {
+			if v, ok := new(big.Rat).SetString(s); !ok || v.Cmp(want) != 0 {
+				t.Errorf("%de%d: spelling %s has value %v", n, k, s, v)
+			}
+		}
+	}
+}
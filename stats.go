@@ -0,0 +1,133 @@
+package fptest
+
+import (
+	"math/big"
+)
+
+// A Histogram counts hard cases by difficulty: Counts[i] is the number
+// of cases such that 2^-(k+1) <= |Eps| < 2^-k, where k = Min+i.
+type Histogram struct {
+	Min    uint
+	Counts []uint64
+}
+
+// add adds n cases to the bucket k.
+func (h *Histogram) add(k uint, n uint64) {
+	if len(h.Counts) == 0 {
+		h.Min = k
+	}
+	for k < h.Min {
+		h.Counts = append([]uint64{0}, h.Counts...)
+		h.Min--
+	}
+	for int(k-h.Min) >= len(h.Counts) {
+		h.Counts = append(h.Counts, 0)
+	}
+	h.Counts[k-h.Min] += n
+}
+
+// merge adds the counts of g to h.
+func (h *Histogram) merge(g Histogram) {
+	for i, n := range g.Counts {
+		h.add(g.Min+uint(i), n)
+	}
+}
+
+// Total returns the number of cases counted by h.
+func (h Histogram) Total() uint64 {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// A RangeStats describes the cases of a range of binary exponents.
+type RangeStats struct {
+	MinExp, MaxExp int // included
+	Histogram
+	Exact uint64 // cases with Eps = 0
+	// Hardest is the hardest inexact case (see HardestCase).
+	// Its ExactEps is nil if there are no cases.
+	Hardest WorstCase
+}
+
+// A Stats describes the difficulty of the cases of a format and mode
+// for a number of digits, over all binary exponents and by ranges
+// of exponents.
+type Stats struct {
+	Digits int
+	RangeStats
+	Ranges []RangeStats
+}
+
+// DifficultyStats counts the cases of format f and mode m with the
+// given number of digits by difficulty, from 2^-precision, for ranges
+// of step binary exponents. The denormal exponent is counted in the
+// first range. The cases are counted as in CountRange, without
+// enumerating them, and the precision must be large enough for the
+// counts to fit in 64 bits.
+func DifficultyStats(f Format, m Mode, digits int, precision uint, step int) Stats {
+	s := Stats{Digits: digits}
+	s.MinExp, s.MaxExp = f.MinExp, f.MaxExp
+	for lo := f.MinExp; lo <= f.MaxExp; lo += step {
+		r := RangeStats{MinExp: lo, MaxExp: lo + step - 1}
+		if r.MaxExp > f.MaxExp {
+			r.MaxExp = f.MaxExp
+		}
+		if lo == f.MinExp {
+			r.addExp(f, m, f.MinExp, digits, precision, true)
+		}
+		for e2 := r.MinExp; e2 <= r.MaxExp; e2++ {
+			r.addExp(f, m, e2, digits, precision, false)
+		}
+		s.merge(r.Histogram)
+		s.Exact += r.Exact
+		s.addHardest(r.Hardest)
+		s.Ranges = append(s.Ranges, r)
+	}
+	return s
+}
+
+// addExp counts the cases of binary exponent e2.
+func (r *RangeStats) addExp(f Format, m Mode, e2 int, digits int, precision uint, denormal bool) {
+	t := f.target(m, e2, digits, denormal)
+	if t == nil {
+		return
+	}
+	r.Exact += t.count(precision, 0)
+	n := t.countWithin(precision)
+	for k := precision; n > 0; k++ {
+		next := t.countWithin(k + 1)
+		r.add(k, n-next)
+		n = next
+	}
+	if c, ok := HardestCase(f, m, e2, digits, denormal); ok {
+		r.addHardest(c)
+	}
+}
+
+// addHardest updates the hardest case of r.
+func (r *RangeStats) addHardest(c WorstCase) {
+	if c.ExactEps == nil {
+		return
+	}
+	if r.Hardest.ExactEps == nil ||
+		new(big.Rat).Abs(c.ExactEps).Cmp(new(big.Rat).Abs(r.Hardest.ExactEps)) < 0 {
+		r.Hardest = c
+	}
+}
+
+// countWithin returns the number of inexact cases of t such that
+// |Eps| < 2^-precision. Below X, the enumerators also include the
+// fraction at the end of their range, which is not counted.
+func (t *target) countWithin(precision uint) uint64 {
+	n := t.count(precision, +1)
+	r1, r2 := ratRange(t.num, t.den, precision, -1, t.nbits)
+	if r1.Less(r2) {
+		below := newCounter(r1, r2, t.nbits, t.denormal, t.parity).total
+		end := newCounter(r1, r1.clone().Next(), t.nbits, t.denormal, t.parity).total
+		n += below - end
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package fptest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDifficultyStats(t *testing.T) {
+	// Compare with the histogram of enumerated cases.
+	for _, m := range []Mode{Midpoints, HalfDecimals, NearFloats} {
+		for _, digits := range []int{6, 9} {
+			prec := uint(2*digits) + 32
+			s := DifficultyStats(Float32, m, digits, prec, 32)
+			var want Histogram
+			hardest := math.Inf(1)
+			BestFirst(Float32, m, digits, prec, 0, func(c HardCase) {
+				if math.Abs(c.Eps) >= math.Ldexp(1, -int(prec)) {
+					t.Errorf("%s: %+v is beyond 2^-%d", m, c, prec)
+				}
+				_, e := math.Frexp(math.Abs(c.Eps))
+				want.add(uint(-e), 1)
+				hardest = math.Min(hardest, math.Abs(c.Eps))
+			})
+			if len(want.Counts) == 0 {
+				t.Fatalf("%s: no cases with %d digits", m, digits)
+			}
+			if s.Min != want.Min || len(s.Counts) != len(want.Counts) {
+				t.Fatalf("%s %d digits: got buckets from %d to %d, want %d to %d", m, digits,
+					s.Min, int(s.Min)+len(s.Counts), want.Min, int(want.Min)+len(want.Counts))
+			}
+			for i := range want.Counts {
+				if s.Counts[i] != want.Counts[i] {
+					t.Errorf("%s %d digits: %d cases at 2^-%d, want %d",
+						m, digits, s.Counts[i], s.Min+uint(i), want.Counts[i])
+				}
+			}
+			if h := math.Abs(s.Hardest.Eps); h != hardest {
+				t.Errorf("%s %d digits: hardest eps=%g, want %g", m, digits, h, hardest)
+			}
+			var total uint64
+			for _, r := range s.Ranges {
+				total += r.Total()
+			}
+			if total != s.Total() || total != want.Total() {
+				t.Errorf("%s %d digits: %d cases in ranges, %d in total, want %d",
+					m, digits, total, s.Total(), want.Total())
+			}
+			t.Logf("%s %d digits: %d cases from 2^-%d, %d exact, hardest %de%d (2^%.1f)",
+				m, digits, s.Total(), s.Min, s.Exact, s.Hardest.N, s.Hardest.K,
+				math.Log2(math.Abs(s.Hardest.Eps)))
+		}
+	}
+}
@@ -3,7 +3,9 @@ package fptest
 import (
 	"bytes"
 	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -504,14 +506,378 @@ func appendE(s []byte, mant uint64, digits int, exp int) ([]byte, bool) {
 	return s2, true
 }
 
-func countDigits(s []byte) (digits int) {
-	for _, c := range s {
-		if '0' <= c && c <= '9' {
-			digits++
-		}
-		if c == 'e' {
+func TestTortureAtof32DoubleRounding(t *testing.T) {
+	// Decimals which are parsed incorrectly by ParseFloat(s, 64)
+	// followed by a float32 conversion.
+	count := 0
+	buf := make([]byte, 32)
+	do := func(xx float64, n uint64, k int) {
+		x := float32(xx)
+		s := strconv.AppendUint(buf[:0], n, 10)
+		s = append(s, 'e')
+		s = strconv.AppendInt(s, int64(k), 10)
+
+		zz, err := strconv.ParseFloat(string(s), 32)
+		if err != nil {
+			t.Errorf("could not parse %q: %s", s, err)
 			return
 		}
+		if z := float32(zz); z != x {
+			t.Errorf("expected to parse %q as %b, got %b", s, x, z)
+		}
+		count++
+	}
+	for digits := 9; digits <= 12; digits++ {
+		count = 0
+		AlmostDoubleRounding(Float64, Float32, Float32.MinExp, digits, true, do)
+		for exp := Float32.MinExp; exp <= Float32.MaxExp; exp++ {
+			AlmostDoubleRounding(Float64, Float32, exp, digits, false, do)
+		}
+		t.Logf("%d digits: %d numbers tested", digits, count)
+	}
+}
+
+// tortureSerialize runs check on the hardest midpoint cases of format f,
+// for both floats around each midpoint, with their shortest decimal
+// representation.
+func tortureSerialize(t *testing.T, f Format, check func(x float64, bits int, n uint64, k int) error) {
+	bits := int(f.bits())
+	maxDigits, basePrec, short := 17, 48, uint64(1e15)
+	if bits == 32 {
+		maxDigits, basePrec, short = 9, 24, 1e6
+	}
+	for digits := maxDigits; digits > 0; digits-- {
+		count := 0
+		prec := basePrec + 3*digits
+		if bits == 32 {
+			prec = basePrec + 2*digits
+		}
+		BestFirst(f, Midpoints, digits, uint(prec), 1000, func(c HardCase) {
+			// The decimal number is the shortest representation
+			// of the float it rounds to, if it is short enough.
+			rounded := c.X
+			if c.Eps > 0 {
+				rounded = f.next(c.X)
+			}
+			for _, x := range []float64{c.X, f.next(c.X)} {
+				n, k := c.N, c.K
+				if x != rounded || c.N >= short {
+					n, k = shortestDecimal(x, bits)
+				}
+				if err := check(x, bits, n, k); err != nil {
+					t.Errorf("%de%d: %s", c.N, c.K, err)
+				}
+				count++
+			}
+		})
+		t.Logf("%d digits: %d numbers tested", digits, count)
+	}
+}
+
+func TestTortureJSON64(t *testing.T) { tortureSerialize(t, Float64, CheckJSON) }
+func TestTortureJSON32(t *testing.T) { tortureSerialize(t, Float32, CheckJSON) }
+func TestTortureFmt64(t *testing.T)  { tortureSerialize(t, Float64, CheckFmt) }
+func TestTortureFmt32(t *testing.T)  { tortureSerialize(t, Float32, CheckFmt) }
+
+func TestTortureFmtFixed(t *testing.T) {
+	// Floats very close to half-decimals, formatted using %.*e.
+	for _, f := range []Format{Float64, Float32} {
+		bits := int(f.bits())
+		maxDigits, basePrec := 17, 48
+		if bits == 32 {
+			maxDigits, basePrec = 9, 24
+		}
+		for digits := maxDigits; digits > 0; digits-- {
+			count, skipped := 0, 0
+			BestFirst(f, HalfDecimals, digits, uint(basePrec+2*digits), 1000, func(c HardCase) {
+				// x is slightly below (n+1/2)×10^k if Eps > 0.
+				n := c.N
+				if c.Eps < 0 {
+					n++
+				}
+				_, ok1 := FormatFixedE(c.N, c.K, digits)
+				_, ok2 := FormatFixedE(n, c.K, digits)
+				if !ok1 || !ok2 {
+					// Too few digits, or rounding to a power of ten.
+					skipped++
+					return
+				}
+				if err := CheckFmtFixed(c.X, bits, n, c.K, digits); err != nil {
+					t.Error(err)
+				}
+				count++
+			})
+			t.Logf("%s, %d digits: %d numbers tested, %d skipped", f.Name, digits, count, skipped)
+		}
+	}
+}
+
+var bigModes = []big.RoundingMode{
+	big.ToNearestEven, big.ToNearestAway, big.ToZero,
+	big.AwayFromZero, big.ToNegativeInf, big.ToPositiveInf,
+}
+
+// bigFloatParse returns the result of big.Float.Parse for n×10^k
+// at precision prec in mode rm, following math/big (floatconv.go):
+// n×2^k is multiplied or divided by 5^|k|, computed at prec+64 bits
+// by binary powering with a factor of prec+128 bits, and rounded
+// to nearest even. The rounding error of 5^|k| makes Parse misround
+// some numbers very close to a rounding boundary.
+func bigFloatParse(n uint64, k int, prec uint, rm big.RoundingMode) *big.Float {
+	z := new(big.Float).SetPrec(prec).SetMode(rm).SetUint64(n)
+	z.SetMantExp(z, k)
+	if k == 0 {
+		return z
+	}
+	e := uint64(k)
+	if k < 0 {
+		e = uint64(-k)
+	}
+	// pow5tab in math/big holds the powers of 5 up to 5^27.
+	const m = 27
+	p := new(big.Float).SetPrec(prec + 64)
+	if e <= m {
+		p.SetInt(new(big.Int).Exp(big.NewInt(5), big.NewInt(int64(e)), nil))
+	} else {
+		p.SetInt(new(big.Int).Exp(big.NewInt(5), big.NewInt(m), nil))
+		e -= m
+		f := new(big.Float).SetPrec(p.Prec() + 64).SetUint64(5)
+		for e > 0 {
+			if e&1 != 0 {
+				p.Mul(p, f)
+			}
+			f.Mul(f, f)
+			e >>= 1
+		}
+	}
+	if k < 0 {
+		return z.Quo(z, p)
+	}
+	return z.Mul(z, p)
+}
+
+func TestTortureBigFloat(t *testing.T) {
+	// big.Float parsing and formatting at several precisions,
+	// for decimals close to midpoints (parsing, in all rounding
+	// modes) and floats close to half-decimals (formatting).
+	//
+	// Parse multiplies by a power of 5 rounded to 64 extra bits,
+	// so some decimals are rounded to the wrong neighbour: they
+	// must be exactly those predicted by bigFloatParse.
+	for _, p := range []uint{64, 113, 256} {
+		for _, digits := range []int{10, 17, 19} {
+			prec := p + uint(3*digits)
+			parsed, formatted, misrounded := 0, 0, 0
+			for e2 := -1200; e2 <= 1200; e2 += 23 {
+				for _, dir := range []int{-1, +1} {
+					AlmostDecimalMidpointBig(e2, digits, p, prec, dir, false, func(x *big.Float, n uint64, k int) {
+						// n×10^k is slightly below (dir > 0) or above
+						// the midpoint of x and y.
+						exp := x.MantExp(nil)
+						y := new(big.Float).SetPrec(p).SetInt64(1)
+						y.Add(y.SetMantExp(y, exp-int(p)), x)
+						s := strconv.FormatUint(n, 10) + "e" + strconv.Itoa(k)
+						for _, mode := range bigModes {
+							want := x
+							switch mode {
+							case big.ToNearestEven, big.ToNearestAway:
+								if dir < 0 {
+									want = y
+								}
+							case big.AwayFromZero, big.ToPositiveInf:
+								want = y
+							}
+							z, _, err := new(big.Float).SetPrec(p).SetMode(mode).Parse(s, 10)
+							if err != nil {
+								t.Fatal(err)
+							}
+							predicted := bigFloatParse(n, k, p, mode)
+							switch {
+							case z.Cmp(predicted) != 0:
+								t.Errorf("prec=%d mode=%s: parsed %s as %s, predicted %s",
+									p, mode, s, z.Text('p', 0), predicted.Text('p', 0))
+							case z.Cmp(want) != 0:
+								misrounded++
+							}
+						}
+						parsed++
+					})
+					AlmostHalfDecimalBig(e2, digits, p, prec, dir, false, func(x *big.Float, n uint64, k int) {
+						// x is slightly above or below (n+1/2)×10^k.
+						if dir > 0 {
+							n++
+						}
+						want, ok := FormatFixedE(n, k, digits)
+						if !ok {
+							return
+						}
+						// Formatting always rounds to nearest, ties to even,
+						// whatever the mode of x.
+						if s := x.Text('e', digits-1); s != want {
+							t.Errorf("prec=%d: formatted %s as %s, want %s",
+								p, x.Text('p', 0), s, want)
+						}
+						formatted++
+					})
+				}
+			}
+			t.Logf("precision %d, %d digits: %d parsed (%d misrounded), %d formatted",
+				p, digits, parsed, misrounded, formatted)
+		}
+	}
+}
+
+func TestTortureBigFloatRounding(t *testing.T) {
+	// big.Float parsing at the precision of float32 and float64,
+	// in all rounding modes, using the hard cases of each mode.
+	for _, f := range []Format{Float32, Float64} {
+		digits := 9
+		if f == Float64 {
+			digits = 17
+		}
+		prec := f.MantBits + uint(2*digits) + 8
+		for _, rm := range bigModes {
+			count := 0
+			BestFirstRounding(f, Midpoints, rm, digits, prec, 2000, func(c Rounded) {
+				// big.Float has neither denormals nor overflow.
+				if c.X < math.Ldexp(1, f.MinExp+int(f.MantBits)-1) || math.IsInf(c.Float, 0) {
+					return
+				}
+				s := strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)
+				z, _, err := new(big.Float).SetPrec(f.MantBits).SetMode(rm).Parse(s, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, _ := z.Float64(); got != c.Float {
+					t.Errorf("%s %s: parsed %s as %b, want %b", f.Name, rm, s, got, c.Float)
+				}
+				count++
+			})
+			t.Logf("%s %s: %d cases", f.Name, rm, count)
+		}
+	}
+}
+
+func TestTortureLongDecimals(t *testing.T) {
+	// Decimal strings of hundreds of digits around the hardest
+	// midpoints, whose rounding is decided just after the 19th, 40th
+	// or 800th digit, as integers and with a decimal point.
+	for _, f := range []Format{Float32, Float64} {
+		digits, bits := 9, 32
+		if f == Float64 {
+			digits, bits = 17, 64
+		}
+		prec := f.MantBits + uint(2*digits) + 8
+		count, misplaced := 0, 0
+		seen := make(map[string]bool)
+		LongDecimals(f, digits, prec, 300, []int{19, 40, 800}, func(c HardCase, s string, want float64) {
+			if seen[s] {
+				t.Errorf("%s: %s...%s is given twice", f.Name, s[:30], s[len(s)-30:])
+			}
+			seen[s] = true
+			i := strings.IndexByte(s, 'e')
+			k, _ := strconv.Atoi(s[i+1:])
+			dotted := s[:1] + "." + s[1:i] + "e" + strconv.Itoa(k+i-1)
+			for _, str := range []string{s, dotted} {
+				x, err := strconv.ParseFloat(str, bits)
+				if err != nil && !math.IsInf(want, 0) {
+					t.Fatal(err)
+				}
+				if x == want {
+					continue
+				}
+				if str == s && i > 800 {
+					// When the fast path cannot decide, strconv drops
+					// the digits of integers beyond the 800th when
+					// computing the decimal point.
+					v, _ := new(big.Rat).SetString(s)
+					if x == f.round(v.Quo(v, pow10Rat(i-800))) {
+						if misplaced++; misplaced > 10 {
+							continue
+						}
+						t.Errorf("%s: ParseFloat(%s...%s) = %b, want %b: the %d-digit integer is parsed as if divided by 10^%d",
+							f.Name, str[:30], str[len(str)-30:], x, want, i, i-800)
+						continue
+					}
+				}
+				t.Errorf("%s: ParseFloat(%s...%s) = %b, want %b",
+					f.Name, str[:30], str[len(str)-30:], x, want)
+			}
+			count++
+		})
+		if misplaced > 10 {
+			t.Errorf("%s: %d long integers parsed with a misplaced decimal point", f.Name, misplaced)
+		}
+		t.Logf("%s: %d strings", f.Name, count)
+	}
+}
+
+func TestTortureWide64(t *testing.T) {
+	// Parsing decimals with 20 to 39 digits close to midpoints,
+	// and formatting floats close to half-decimals with 20 to 39 digits.
+	for digits := 20; digits <= 39; digits++ {
+		prec := uint(53 + 33*digits/10)
+		parsed, formatted := 0, 0
+		for e2 := -1074; e2 <= 971; e2 += 3 {
+			for _, dir := range []int{-1, +1} {
+				AlmostDecimalMidpoint128(e2, digits, 53, prec, dir, false, func(x float64, n [2]uint64, k int) {
+					// n×10^k is slightly below (dir > 0) or above
+					// the midpoint of x and its successor.
+					want := x
+					if dir < 0 {
+						want = math.Nextafter(x, math.Inf(1))
+					}
+					s := bigUint128(n).String() + "e" + strconv.Itoa(k)
+					if y, _ := strconv.ParseFloat(s, 64); y != want {
+						t.Errorf("ParseFloat(%s) = %b, want %b", s, y, want)
+					}
+					parsed++
+				})
+				AlmostHalfDecimal128(e2, digits, 53, prec, dir, false, func(x float64, n [2]uint64, k int) {
+					// x is slightly above or below (n+1/2)×10^k.
+					N := bigUint128(n)
+					if dir > 0 {
+						N.Add(N, big.NewInt(1))
+					}
+					ds := N.String()
+					if len(ds) != digits {
+						return
+					}
+					want := formatE(ds, k+digits-1, 2)
+					if s := strconv.FormatFloat(x, 'e', digits-1, 64); s != want {
+						t.Errorf("FormatFloat(%b, 'e', %d) = %s, want %s", x, digits-1, s, want)
+					}
+					formatted++
+				})
+			}
+		}
+		t.Logf("%d digits: %d parsed, %d formatted", digits, parsed, formatted)
+	}
+}
+
+func TestTortureSpellings(t *testing.T) {
+	// All spellings of the hardest decimals must be parsed
+	// to the same float.
+	for _, f := range []Format{Float32, Float64} {
+		digits, bits := 9, 32
+		if f == Float64 {
+			digits, bits = 17, 64
+		}
+		prec := f.MantBits + uint(2*digits) + 8
+		count := 0
+		BestFirst(f, Midpoints, digits, prec, 1000, func(c HardCase) {
+			want := f.Rounded(c, Midpoints, big.ToNearestEven).Float
+			for _, s := range Spell(c.N, c.K, AllSpellings) {
+				x, err := strconv.ParseFloat(s, bits)
+				if err != nil && !math.IsInf(want, 0) {
+					t.Errorf("ParseFloat(%s): %s", s, err)
+				}
+				if x != want {
+					t.Errorf("ParseFloat(%s) = %b, want %b", s, x, want)
+				}
+				count++
+			}
+		})
+		t.Logf("%s: %d strings", f.Name, count)
 	}
-	return
 }
@@ -0,0 +1,175 @@
+package fptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A Vector is a test vector given by a hard case: the bits of a float
+// and a decimal string. For Midpoints and NearFloats, the string must
+// be parsed to the float (rounded to nearest, ties to even). For
+// HalfDecimals, the float must be formatted to the string by %.*e
+// with digits-1 decimals.
+type Vector struct {
+	Bits   uint64
+	String string
+}
+
+// A VectorFile is a table of test vectors, written as source files
+// for other languages so that they do not need to run the enumeration.
+type VectorFile struct {
+	Format    Format
+	Mode      Mode
+	Digits    int
+	Precision uint
+	Count     int
+	// Command is the command which generated the file,
+	// written in its header if not empty.
+	Command string
+	Vectors []Vector
+}
+
+// NewVectorFile returns the vectors of the count hardest cases of format
+// f (Float32 or Float64) and mode m, as enumerated by BestFirst.
+func NewVectorFile(f Format, m Mode, digits int, precision uint, count int) *VectorFile {
+	if f != Float32 && f != Float64 {
+		panic("unsupported format " + f.Name)
+	}
+	v := &VectorFile{Format: f, Mode: m, Digits: digits, Precision: precision, Count: count}
+	BestFirst(f, m, digits, precision, count, func(c HardCase) {
+		r := f.Rounded(c, m, big.ToNearestEven)
+		if m != HalfDecimals {
+			s := strconv.FormatUint(c.N, 10) + "e" + strconv.Itoa(c.K)
+			v.Vectors = append(v.Vectors, Vector{f.floatBits(r.Float), s})
+			return
+		}
+		if s, ok := FormatFixedE(r.Decimal, c.K, digits); ok {
+			v.Vectors = append(v.Vectors, Vector{f.floatBits(c.X), s})
+		}
+	})
+	return v
+}
+
+// floatBits returns the bit pattern of x in format f.
+func (f Format) floatBits(x float64) uint64 {
+	if f.bits() == 32 {
+		return uint64(math.Float32bits(float32(x)))
+	}
+	return math.Float64bits(x)
+}
+
+// name returns the name of the table, such as float64_midpoints.
+func (v *VectorFile) name() string {
+	return v.Format.Name + "_" + v.Mode.String()
+}
+
+// header returns the comment lines describing the file.
+func (v *VectorFile) header() []string {
+	var lines []string
+	if v.Command != "" {
+		lines = append(lines, "Code generated by "+v.Command+"; DO NOT EDIT.", "")
+	}
+	lines = append(lines,
+		"Hard cases of github.com/remyoudompheng/fptest:",
+		fmt.Sprintf("format=%s mode=%s digits=%d precision=%d count=%d",
+			v.Format.Name, v.Mode, v.Digits, v.Precision, v.Count))
+	if v.Mode == HalfDecimals {
+		lines = append(lines, fmt.Sprintf("Each float (as bits) formats to the string with %%.%de.", v.Digits-1))
+	} else {
+		lines = append(lines, fmt.Sprintf("Each string parses to the nearest %s (as bits), ties to even.", v.Format.Name))
+	}
+	return lines
+}
+
+// bitsLiteral returns the hexadecimal literal of the bits of a vector.
+func (v *VectorFile) bitsLiteral(b uint64) string {
+	if v.Format.bits() == 32 {
+		return fmt.Sprintf("0x%08x", b)
+	}
+	return fmt.Sprintf("0x%016x", b)
+}
+
+// WriteC writes v as a C header declaring a static array
+// of structs with uint32_t or uint64_t bits and strings.
+func (v *VectorFile) WriteC(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, l := range v.header() {
+		fmt.Fprintln(b, strings.TrimSpace("// "+l))
+	}
+	name := "fptest_" + v.name()
+	guard := strings.ToUpper(name) + "_H"
+	typ := fmt.Sprintf("uint%d_t", v.Format.bits())
+	fmt.Fprintf(b, "\n#ifndef %s\n#define %s\n\n#include <stdint.h>\n\n", guard, guard)
+	fmt.Fprintf(b, "static const struct %s_vector {\n\t%s bits;\n\tconst char *str;\n} %s[] = {\n", name, typ, name)
+	for _, t := range v.Vectors {
+		fmt.Fprintf(b, "\t{UINT%d_C(%s), %q},\n", v.Format.bits(), v.bitsLiteral(t.Bits), t.String)
+	}
+	fmt.Fprintf(b, "};\n\n#define %s_COUNT %d\n\n#endif // %s\n", strings.ToUpper(name), len(v.Vectors), guard)
+	return b.Flush()
+}
+
+// WriteRust writes v as a Rust module declaring a constant
+// array of (u32 or u64, &str) tuples.
+func (v *VectorFile) WriteRust(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, l := range v.header() {
+		fmt.Fprintln(b, strings.TrimSpace("// "+l))
+	}
+	fmt.Fprintf(b, "\npub const %s: [(u%d, &str); %d] = [\n",
+		strings.ToUpper(v.name()), v.Format.bits(), len(v.Vectors))
+	for _, t := range v.Vectors {
+		fmt.Fprintf(b, "    (%s, %q),\n", v.bitsLiteral(t.Bits), t.String)
+	}
+	fmt.Fprintf(b, "];\n")
+	return b.Flush()
+}
+
+// WriteGo writes v as a Go test file of package pkg, declaring
+// a table and a test checking it against strconv.
+func (v *VectorFile) WriteGo(w io.Writer, pkg string) error {
+	b := bufio.NewWriter(w)
+	for _, l := range v.header() {
+		fmt.Fprintln(b, strings.TrimSpace("// "+l))
+	}
+	bits := v.Format.bits()
+	// float64_midpoints: float64Midpoints, TestFloat64Midpoints
+	name := v.Format.Name + title(v.Mode.String())
+	fmt.Fprintf(b, "\npackage %s\n\nimport (\n\t\"math\"\n\t\"strconv\"\n\t\"testing\"\n)\n\n", pkg)
+	fmt.Fprintf(b, "var %s = []struct {\n\tbits uint%d\n\tstr  string\n}{\n", name, bits)
+	for _, t := range v.Vectors {
+		fmt.Fprintf(b, "\t{%s, %q},\n", v.bitsLiteral(t.Bits), t.String)
+	}
+	fmt.Fprintf(b, "}\n\n")
+	fmt.Fprintf(b, "func Test%s(t *testing.T) {\n", title(name))
+	fmt.Fprintf(b, "\tfor _, c := range %s {\n", name)
+	if v.Mode == HalfDecimals {
+		if bits == 32 {
+			fmt.Fprintf(b, "\t\tx := float64(math.Float32frombits(c.bits))\n")
+		} else {
+			fmt.Fprintf(b, "\t\tx := math.Float64frombits(c.bits)\n")
+		}
+		fmt.Fprintf(b, "\t\tif s := strconv.FormatFloat(x, 'e', %d, %d); s != c.str {\n", v.Digits-1, bits)
+		fmt.Fprintf(b, "\t\t\tt.Errorf(\"%%#x: got %%s, want %%s\", c.bits, s, c.str)\n")
+	} else {
+		fmt.Fprintf(b, "\t\tx, err := strconv.ParseFloat(c.str, %d)\n", bits)
+		fmt.Fprintf(b, "\t\tif err != nil {\n\t\t\tt.Fatal(err)\n\t\t}\n")
+		if bits == 32 {
+			fmt.Fprintf(b, "\t\tif got := math.Float32bits(float32(x)); got != c.bits {\n")
+		} else {
+			fmt.Fprintf(b, "\t\tif got := math.Float64bits(x); got != c.bits {\n")
+		}
+		fmt.Fprintf(b, "\t\t\tt.Errorf(\"%%s: got %%#x, want %%#x\", c.str, got, c.bits)\n")
+	}
+	fmt.Fprintf(b, "\t\t}\n\t}\n}\n")
+	return b.Flush()
+}
+
+// title returns s with its first letter in upper case.
+func title(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,90 @@
+package fptest
+
+import (
+	"bytes"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestVectorFile(t *testing.T) {
+	for _, f := range []Format{Float32, Float64} {
+		for _, m := range []Mode{Midpoints, HalfDecimals, NearFloats} {
+			digits := 9
+			if f == Float64 {
+				digits = 17
+			}
+			v := NewVectorFile(f, m, digits, f.MantBits+uint(2*digits), 20)
+			v.Command = "mktest vectors"
+			if len(v.Vectors) < 10 {
+				t.Fatalf("%s %s: only %d vectors", f.Name, m, len(v.Vectors))
+			}
+			bits := int(f.bits())
+			for _, c := range v.Vectors {
+				if m == HalfDecimals {
+					x := math.Float64frombits(c.Bits)
+					if bits == 32 {
+						x = float64(math.Float32frombits(uint32(c.Bits)))
+					}
+					if s := strconv.FormatFloat(x, 'e', digits-1, bits); s != c.String {
+						t.Errorf("%s %s: %#x formats to %s, want %s", f.Name, m, c.Bits, s, c.String)
+					}
+					continue
+				}
+				x, _ := strconv.ParseFloat(c.String, bits)
+				if b := f.floatBits(x); b != c.Bits {
+					t.Errorf("%s %s: %s parses to %#x, want %#x", f.Name, m, c.String, b, c.Bits)
+				}
+			}
+
+			var src bytes.Buffer
+			if err := v.WriteGo(&src, "vectors_test"); err != nil {
+				t.Fatal(err)
+			}
+			out, err := format.Source(src.Bytes())
+			if err != nil {
+				t.Fatalf("%s %s: invalid Go source: %s\n%s", f.Name, m, err, &src)
+			}
+			if !bytes.Equal(out, src.Bytes()) {
+				t.Errorf("%s %s: Go source is not formatted:\n%s", f.Name, m, &src)
+			}
+			if !strings.HasPrefix(src.String(), "// Code generated by mktest vectors; DO NOT EDIT.\n") {
+				t.Errorf("%s %s: missing generated code header", f.Name, m)
+			}
+
+			params := "format=" + f.Name + " mode=" + m.String() + " digits=" + strconv.Itoa(digits)
+			for _, write := range []func(*bytes.Buffer) error{
+				func(b *bytes.Buffer) error { return v.WriteGo(b, "vectors_test") },
+				func(b *bytes.Buffer) error { return v.WriteC(b) },
+				func(b *bytes.Buffer) error { return v.WriteRust(b) },
+			} {
+				var b bytes.Buffer
+				if err := write(&b); err != nil {
+					t.Fatal(err)
+				}
+				s := b.String()
+				if !strings.Contains(s, params) {
+					t.Errorf("%s %s: missing parameters %q:\n%s", f.Name, m, params, s)
+				}
+				for _, c := range v.Vectors {
+					if !containsLine(s, v.bitsLiteral(c.Bits), strconv.Quote(c.String)) {
+						t.Errorf("%s %s: missing vector %#x %s:\n%s", f.Name, m, c.Bits, c.String, s)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// containsLine returns whether a line of s contains both a and b.
+func containsLine(s, a, b string) bool {
+	for _, l := range strings.Split(s, "\n") {
+		if strings.Contains(l, a) && strings.Contains(l, b) {
+			return true
+		}
+	}
+	return false
+}
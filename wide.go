@@ -0,0 +1,196 @@
+package fptest
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// AlmostDecimalMidpoint128 is like AlmostDecimalMidpoint for decimal
+// numbers with 20 to 39 digits: the decimal mantissa n has up
+// to 128 bits, as a pair {hi, lo} of 64-bit words.
+//
+// The numerators of the fractions walked by AlmostDecimalMidpoint
+// are then wider than 64 bits: the Farey sequence is walked by
+// a Rat on the fractional part, with the integer part kept
+// as an offset (see wideRat).
+// Cases whose decimal mantissa does not fit in 128 bits are skipped.
+func AlmostDecimalMidpoint128(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	f func(x float64, n [2]uint64, k int)) {
+	if t := midpointTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walk128(precision, -direction, f)
+	}
+}
+
+// AlmostHalfDecimal128 is like AlmostHalfDecimal for decimal
+// numbers with 20 to 39 digits (see AlmostDecimalMidpoint128).
+func AlmostHalfDecimal128(e2 int, digits int, mantbits, precision uint, direction int, denormal bool,
+	f func(x float64, n [2]uint64, k int)) {
+	if t := halfDecimalTarget(e2, digits, mantbits, denormal); t != nil {
+		t.walk128(precision, -direction, f)
+	}
+}
+
+// walk128 is like walkBig for fractions with numerators wider than
+// 64 bits, walked as wideRats. The numerators of half-decimals
+// (2n+1)/mant have one more bit than n, so numerators are handled
+// as 192-bit words {top, hi, lo}.
+func (t *target) walk128(precision uint, direction int, f func(x float64, n [2]uint64, k int)) {
+	lo, up, ok := newWideRat(t.num, t.den, t.nbits)
+	if !ok {
+		// Only mantissas below 2 could give decimal
+		// mantissas of 128 bits.
+		return
+	}
+	exact := lo.r.Equals(up.r)
+	if direction == 0 {
+		if exact {
+			t.emit128(lo.num192(), lo.r.c, f)
+		}
+		return
+	}
+	r := lo
+	if direction > 0 {
+		r = up
+		if exact {
+			r.r.Next()
+		}
+	} else if exact && !r.prev() {
+		return
+	}
+	// Walk away from X until the precision is exceeded.
+	X := bigFrac{t.num, t.den}
+	for {
+		a, b := r.num192(), r.r.c
+		p := new(big.Int).SetUint64(a[0])
+		p.Lsh(p, 128)
+		p.Or(p, bigUint128([2]uint64{a[1], a[2]}))
+		if !withinBig(bigFrac{p, new(big.Int).SetUint64(b)}, X, precision) {
+			return
+		}
+		if a[0] <= 1 {
+			// Otherwise the decimal mantissa overflows 128 bits.
+			t.emit128(a, b, f)
+		}
+		if direction > 0 {
+			r.r.Next()
+		} else if !r.prev() {
+			return
+		}
+	}
+}
+
+// A wideRat is a positive rational number off + r, with an integer
+// offset of 128 bits. Farey sequences are invariant by integer
+// translation, so the Rat r walks the fractional part and its
+// numerators fit in 64 bits.
+type wideRat struct {
+	off [2]uint64
+	r   *Rat
+}
+
+// newWideRat is like NewRatFromBig for wideRats. ok is false if the
+// integer part of num/den does not fit in 128 bits.
+func newWideRat(num, den *big.Int, maxBits uint) (lower, upper *wideRat, ok bool) {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if quo.BitLen() > 128 {
+		return nil, nil, false
+	}
+	lo, up := NewRatFromBig(rem, den, maxBits)
+	off := uint128(quo)
+	return &wideRat{off: off, r: lo}, &wideRat{off: off, r: up}, true
+}
+
+// prev mutates w to its predecessor in the Farey sequence.
+// It returns false if w is 0/1.
+func (w *wideRat) prev() bool {
+	if w.r.a == 0 {
+		if w.off == ([2]uint64{}) {
+			return false
+		}
+		// off + 0/1 is (off-1) + 1/1.
+		if w.off[1] == 0 {
+			w.off[0]--
+		}
+		w.off[1]--
+		w.r = NewRatFromCF([]uint64{1}, w.r.maxBits)
+	}
+	w.r.Prev()
+	return true
+}
+
+// num192 returns the numerator off×c + a of w,
+// as words {top, hi, lo}.
+func (w *wideRat) num192() [3]uint64 {
+	hi, lo := bits.Mul64(w.off[1], w.r.c)
+	top, mid := bits.Mul64(w.off[0], w.r.c)
+	hi, carry := bits.Add64(hi, mid, 0)
+	top += carry
+	lo, carry = bits.Add64(lo, w.r.a, 0)
+	hi, carry = bits.Add64(hi, 0, carry)
+	return [3]uint64{top + carry, hi, lo}
+}
+
+// emit128 is like emitAll for a fraction a/b with a 192-bit numerator.
+func (t *target) emit128(a [3]uint64, b uint64, f func(x float64, n [2]uint64, k int)) {
+	if t.parity == OddNumerator && a[2]%2 == 0 || t.parity == OddDenominator && b%2 == 0 {
+		// All multiples are even.
+		return
+	}
+	multiples128(a, b, t.nbits, t.denormal, func(a [3]uint64, b uint64) {
+		switch t.parity {
+		case OddNumerator:
+			if a[2]%2 == 1 && a[0] <= 1 {
+				// The decimal is (n+1/2)×10^k with 2n+1 = a.
+				n := [2]uint64{a[0]<<63 | a[1]>>1, a[1]<<63 | a[2]>>1}
+				f(math.Ldexp(float64(b), t.exp2), n, t.exp10)
+			}
+		case OddDenominator:
+			if b%2 == 1 && a[0] == 0 {
+				f(math.Ldexp(float64(b/2), t.exp2), [2]uint64{a[1], a[2]}, t.exp10)
+			}
+		}
+	})
+}
+
+// multiples128 is like multiples for a numerator of 192-bit words
+// {top, hi, lo}: it stops at multiples whose numerator overflows
+// 129 bits, which cannot give 128-bit decimal mantissas.
+func multiples128(a [3]uint64, b uint64, nbits uint, denormal bool, f func(a [3]uint64, b uint64)) {
+	if a == [3]uint64{} {
+		return
+	}
+	if !denormal && bits.Len64(b) == int(nbits) {
+		f(a, b)
+		return
+	}
+	max := ^uint64(0) >> (64 - nbits)
+	j := uint64(1)
+	if !denormal {
+		j = (max/2 + b) / b
+	}
+	for ; j <= max/b; j++ {
+		hi, lo := bits.Mul64(j, a[2])
+		top, mid := bits.Mul64(j, a[1])
+		hi, carry := bits.Add64(hi, mid, 0)
+		top, carry = bits.Add64(top, j*a[0], carry)
+		if top > 1 || carry != 0 {
+			return
+		}
+		f([3]uint64{top, hi, lo}, j*b)
+	}
+}
+
+// uint128 returns the words {hi, lo} of an integer of at most 128 bits.
+func uint128(n *big.Int) [2]uint64 {
+	lo := new(big.Int).And(n, new(big.Int).SetUint64(math.MaxUint64))
+	hi := new(big.Int).Rsh(n, 64)
+	return [2]uint64{hi.Uint64(), lo.Uint64()}
+}
+
+// bigUint128 returns n as a big.Int.
+func bigUint128(n [2]uint64) *big.Int {
+	b := new(big.Int).SetUint64(n[0])
+	b.Lsh(b, 64)
+	return b.Or(b, new(big.Int).SetUint64(n[1]))
+}
@@ -0,0 +1,188 @@
+package fptest
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestAlmostDecimalMidpoint128(t *testing.T) {
+	// With at most 19 digits, the cases are those
+	// of the 64-bit enumerators.
+	type hcase struct {
+		x float64
+		n uint64
+		k int
+	}
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		count := 0
+		enum, enum128 := AlmostDecimalMidpoint, AlmostDecimalMidpoint128
+		if m == HalfDecimals {
+			enum, enum128 = AlmostHalfDecimal, AlmostHalfDecimal128
+		}
+		for e2 := -1074; e2 < 972; e2 += 29 {
+			for _, dir := range []int{-1, +1} {
+				var want, got []hcase
+				enum(e2, 17, 53, 100, dir, false, func(x float64, n uint64, k int) {
+					want = append(want, hcase{x, n, k})
+				})
+				enum128(e2, 17, 53, 100, dir, false, func(x float64, n [2]uint64, k int) {
+					if n[0] != 0 {
+						t.Errorf("%s e2=%d: 128-bit numerator %v", m, e2, n)
+					}
+					got = append(got, hcase{x, n[1], k})
+				})
+				// The Farey range of the 64-bit enumerators
+				// may include fractions beyond the precision.
+				i := 0
+				for _, c := range want {
+					if i < len(got) && got[i] == c {
+						i++
+					}
+				}
+				if i != len(got) {
+					t.Errorf("%s e2=%d dir=%d: got %v, want %v", m, e2, dir, got, want)
+				}
+				count += len(got)
+			}
+		}
+		if count < 10000 {
+			t.Errorf("%s: only %d cases", m, count)
+		}
+		t.Logf("%s: %d cases", m, count)
+	}
+}
+
+func TestAlmost128Digits(t *testing.T) {
+	// Check the relative difference of cases with 20 to 39 digits.
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		enum128 := AlmostDecimalMidpoint128
+		if m == HalfDecimals {
+			enum128 = AlmostHalfDecimal128
+		}
+		for _, digits := range []int{20, 25, 30, 39} {
+			prec := uint(53 + 33*digits/10)
+			count := 0
+			for e2 := -1000; e2 < 900; e2 += 37 {
+				for _, dir := range []int{-1, +1} {
+					enum128(e2, digits, 53, prec, dir, false, func(x float64, n [2]uint64, k int) {
+						count++
+						if l := len(bigUint128(n).String()); l < digits-1 || l > digits+1 {
+							t.Errorf("%s: %v has %d digits", m, n, l)
+						}
+						// The decimal and the binary number.
+						d := new(big.Rat).SetInt(bigUint128(n))
+						bin := new(big.Rat).SetFloat64(x)
+						_, e := math.Frexp(x)
+						if m == Midpoints {
+							bin.Add(bin, pow2Rat(e-54))
+						} else {
+							d.Add(d, big.NewRat(1, 2))
+						}
+						d.Mul(d, decimalRat(1, k))
+						eps := new(big.Rat).Quo(d, bin)
+						eps.Sub(eps, big.NewRat(1, 1))
+						if eps.Sign() != -dir {
+							t.Errorf("%s: %v×10^%d is on the wrong side of %b", m, n, k, x)
+						}
+						if f, _ := eps.Float64(); math.Abs(f) >= math.Ldexp(1, -int(prec)) {
+							t.Errorf("%s: %v×10^%d is too far from %b (eps=%g)", m, n, k, x, f)
+						}
+					})
+				}
+			}
+			if count == 0 {
+				t.Errorf("%s: no cases with %d digits", m, digits)
+			}
+			t.Logf("%s: %d cases with %d digits", m, count, digits)
+		}
+	}
+}
+
+func TestAlmost128Big(t *testing.T) {
+	// Compare with the Farey sequence walked with big integers.
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		for _, digits := range []int{20, 29, 39} {
+			prec := uint(53 + 33*digits/10)
+			count := 0
+			for e2 := -1074; e2 < 972; e2 += 41 {
+				tg := Float64.target(m, e2, digits, false)
+				if tg == nil {
+					continue
+				}
+				for _, dir := range []int{-1, +1} {
+					var want, got []string
+					tg.walkBig(prec, dir, func(n, mant *big.Int) {
+						if n.BitLen() <= 128 {
+							want = append(want, fmt.Sprintf("%d %d", n, mant))
+						}
+					})
+					tg.walk128(prec, dir, func(x float64, n [2]uint64, k int) {
+						mant, _ := new(big.Float).SetFloat64(math.Ldexp(x, -tg.exp2)).Int(nil)
+						got = append(got, fmt.Sprintf("%d %d", bigUint128(n), mant))
+					})
+					if fmt.Sprint(got) != fmt.Sprint(want) {
+						t.Errorf("%s digits=%d e2=%d dir=%d: got %v, want %v", m, digits, e2, dir, got, want)
+					}
+					count += len(got)
+				}
+			}
+			if count == 0 {
+				t.Errorf("%s: no cases with %d digits", m, digits)
+			}
+		}
+	}
+}
+
+func TestWideRat(t *testing.T) {
+	// Numerators wider than 64 bits: walk the Farey sequence
+	// around X and compare with big integers. Walks around 2^70
+	// cross integers.
+	for _, c := range []struct {
+		num, den *big.Int
+		maxBits  uint
+	}{
+		{new(big.Int).Exp(big.NewInt(10), big.NewInt(25), nil), big.NewInt(7919), 10},
+		{new(big.Int).Lsh(big.NewInt(1), 70), big.NewInt(1), 4},
+	} {
+		N := new(big.Int).SetUint64(1<<c.maxBits - 1)
+		for _, dir := range []int{-1, +1} {
+			lo, hi := fareyBounds(c.num, c.den, N)
+			r, up, ok := newWideRat(c.num, c.den, c.maxBits)
+			if !ok {
+				t.Fatalf("%s/%s: integer part overflows", c.num, c.den)
+			}
+			prev, want := hi, lo
+			if dir > 0 {
+				r = up
+				prev, want = lo, hi
+			}
+			if lo.cmp(hi) == 0 {
+				// X is an integer, its neighbours are X ± 1/N.
+				p := new(big.Int).Mul(c.num, N)
+				prev = bigFrac{p.Sub(p, big.NewInt(int64(dir))), N}
+			}
+			for i := 0; i < 300; i++ {
+				a := r.num192()
+				got := bigFrac{bigUint128([2]uint64{a[1], a[2]}), new(big.Int).SetUint64(r.r.c)}
+				if a[0] != 0 || got.cmp(want) != 0 || got.q.Cmp(want.q) != 0 {
+					t.Fatalf("%s/%s dir=%d step %d: got %s/%s, expected %s/%s",
+						c.num, c.den, dir, i, got.p, got.q, want.p, want.q)
+				}
+				prev, want = want, fareyNext(prev, want, N)
+				if dir > 0 {
+					r.r.Next()
+				} else if !r.prev() {
+					t.Fatalf("%s/%s step %d: no predecessor", c.num, c.den, i)
+				}
+			}
+		}
+	}
+
+	// 0/1 has no predecessor.
+	r, _, _ := newWideRat(big.NewInt(0), big.NewInt(1), 4)
+	if r.prev() {
+		t.Errorf("0/1 has a predecessor %s", r.r.BigRat())
+	}
+}
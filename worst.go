@@ -0,0 +1,71 @@
+package fptest
+
+import (
+	"math/big"
+)
+
+// A WorstCase is the hardest case for a binary exponent
+// and number of digits, with the exact value of its Eps.
+type WorstCase struct {
+	HardCase
+	Digits   int
+	Denormal bool
+	ExactEps *big.Rat
+}
+
+// HardestCase returns the hardest inexact case (the smallest nonzero
+// |Eps|) of mode m for binary exponent e2 and the given number of digits,
+// or false if there are no cases.
+//
+// The hardest cases on both sides of X are the best rational
+// approximations given by NewRatFromBig, or their nearest Farey
+// neighbours with valid multiples, so no threshold is needed.
+func HardestCase(f Format, m Mode, e2 int, digits int, denormal bool) (WorstCase, bool) {
+	t := f.target(m, e2, digits, denormal)
+	if t == nil {
+		return WorstCase{}, false
+	}
+	x := new(big.Rat).SetFrac(t.num, t.den)
+	var best WorstCase
+	found := false
+	for _, dir := range []int{-1, +1} {
+		// A precision of 1 bit stops the walk at a factor 2 from X.
+		for it := newRatIter(t.num, t.den, 1, dir, t.nbits); !it.done; it.next() {
+			var c HardCase
+			ok := false
+			t.emitAll(it.r, func(x float64, n uint64, k int) {
+				if !ok {
+					c = HardCase{X: x, Exp: e2, N: n, K: k}
+					ok = true
+				}
+			})
+			if !ok {
+				continue
+			}
+			eps := it.r.BigRat()
+			eps.Quo(eps, x)
+			eps.Sub(eps, big.NewRat(1, 1))
+			if !found || new(big.Rat).Abs(eps).Cmp(new(big.Rat).Abs(best.ExactEps)) < 0 {
+				c.Eps, _ = eps.Float64()
+				best = WorstCase{HardCase: c, Digits: digits, Denormal: denormal, ExactEps: eps}
+				found = true
+			}
+			break
+		}
+	}
+	return best, found
+}
+
+// WorstCases calls fn with the hardest case of each binary
+// exponent of format f (see HardestCase), the denormal
+// exponent coming first.
+func WorstCases(f Format, m Mode, digits int, fn func(c WorstCase)) {
+	if c, ok := HardestCase(f, m, f.MinExp, digits, true); ok {
+		fn(c)
+	}
+	for e2 := f.MinExp; e2 <= f.MaxExp; e2++ {
+		if c, ok := HardestCase(f, m, e2, digits, false); ok {
+			fn(c)
+		}
+	}
+}
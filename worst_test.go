@@ -0,0 +1,73 @@
+package fptest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHardestCase(t *testing.T) {
+	// Compare with the enumeration of all cases at a low precision.
+	const prec = 24
+	for _, m := range []Mode{Midpoints, HalfDecimals} {
+		enum := AlmostDecimalMidpoint
+		if m == HalfDecimals {
+			enum = AlmostHalfDecimal
+		}
+		checked := 0
+		for digits := 1; digits <= 3; digits++ {
+			for e2 := Float32.MinExp; e2 <= Float32.MaxExp; e2 += 7 {
+				min := math.Inf(1)
+				for _, dir := range []int{-1, 1} {
+					enum(e2, digits, 24, prec, dir, false, func(x float64, n uint64, k int) {
+						if eps := math.Abs(caseEps(Float32, m, x, n, k)); eps < min {
+							min = eps
+						}
+					})
+				}
+				c, ok := HardestCase(Float32, m, e2, digits, false)
+				if !ok {
+					if !math.IsInf(min, 1) {
+						t.Errorf("%s e2=%d digits=%d: no hardest case, expected eps=%g", m, e2, digits, min)
+					}
+					continue
+				}
+				eps := caseEps(Float32, m, c.X, c.N, c.K)
+				exact, _ := c.ExactEps.Float64()
+				if exact != c.Eps || math.Abs(eps-c.Eps) > 1e-9*math.Abs(eps) {
+					t.Errorf("%s: %+v: eps=%g", m, c, eps)
+				}
+				if !math.IsInf(min, 1) {
+					checked++
+					if math.Abs(math.Abs(eps)-min) > 1e-9*min {
+						t.Errorf("%s e2=%d digits=%d: got %+v, expected |eps|=%g", m, e2, digits, c, min)
+					}
+				}
+			}
+		}
+		t.Logf("%s: %d exponents checked", m, checked)
+		if checked < 20 {
+			t.Errorf("%s: too few exponents checked", m)
+		}
+	}
+}
+
+func TestWorstCases(t *testing.T) {
+	for _, f := range []Format{Float32, Float64} {
+		n := 0
+		hardest := WorstCase{}
+		WorstCases(f, Midpoints, 17, func(c WorstCase) {
+			n++
+			if c.Eps == 0 || c.ExactEps.Sign() == 0 {
+				t.Errorf("exact case %+v", c)
+			}
+			if hardest.ExactEps == nil || math.Abs(c.Eps) < math.Abs(hardest.Eps) {
+				hardest = c
+			}
+		})
+		t.Logf("%s: %d exponents, hardest %de%d eps=%.3e (2^%.1f)", f.Name, n,
+			hardest.N, hardest.K, hardest.Eps, math.Log2(math.Abs(hardest.Eps)))
+		if n < f.MaxExp-f.MinExp {
+			t.Errorf("%s: got only %d exponents", f.Name, n)
+		}
+	}
+}